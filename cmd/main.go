@@ -2,17 +2,31 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
-	"strings"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"transport/internal/auth"
 	"transport/internal/config"
+	"transport/internal/contact"
+	"transport/internal/forward"
 	"transport/internal/handler"
+	"transport/internal/health"
 	"transport/internal/kafka"
+	"transport/internal/keyring"
+	"transport/internal/metrics"
+	"transport/internal/queue"
+	"transport/internal/routing"
 	"transport/internal/service"
 )
 
@@ -26,30 +40,187 @@ func init() {
 
 func main() {
 	cfg := config.Load()
+	metrics.SetSenders(cfg.MetricsSenders)
+
+	// План сеансов связи
+	contactPlan := contact.NewPlan(cfg.ContactPlan)
+	if err := contactPlan.Reload(context.Background()); err != nil {
+		log.Fatalf("[Contact] Failed to load contact plan: %v", err)
+	}
+	contacts := contact.NewScheduler(contactPlan)
+
+	// Граф контактов для маршрутизации через ретрансляторы
+	cgr := routing.NewRouter(cfg.NodeID, cfg.RoutingGraph)
+	if err := cgr.Reload(); err != nil {
+		log.Fatalf("[Routing] Failed to load contact graph: %v", err)
+	}
+
+	// Ключи шифрования содержимого сообщений
+	keys := keyring.New(cfg.EncryptionKeyring)
+	if err := keys.Reload(); err != nil {
+		log.Fatalf("[Keyring] Failed to load keyring: %v", err)
+	}
+
+	// Подпись кадров ключами узлов
+	signer, err := auth.New(cfg.NodeID, cfg.AuthKeys, cfg.ReplayWindow)
+	if err != nil {
+		log.Fatalf("[Auth] Invalid AUTH_KEYS: %v", err)
+	}
 
 	// Инициализация компонентов
-	tracker := service.NewAckTracker(cfg)
-	reassembler := service.NewReassembler(cfg)
-	producer := kafka.NewProducer(strings.Split(cfg.KafkaBrokers, ","), "segment-topic")
-	handler := handler.NewTransportHandler(producer, reassembler, cfg, tracker)
+	events := service.NewEventBus()
+	outbox := service.NewOutbox(cfg)
+	webhookOutbox := service.NewWebhookOutbox(cfg)
+	webhooks := service.NewWebhookRegistry(cfg, webhookOutbox)
+	fanout := service.NewFanOut()
+	tracker := service.NewAckTracker(cfg, outbox, webhooks, events, contactPlan, fanout)
+	reassembler := service.NewReassembler(cfg, events, cgr, keys, signer)
+	segmentQueue := newSegmentQueue(cfg)
+	transportHandler := handler.NewTransportHandler(segmentQueue, reassembler, cfg, tracker, outbox, webhooks, events, cgr, fanout, keys, signer)
+	dlq := forward.NewDLQ(segmentQueue, cfg.DLQTopic, cfg.KafkaSegmentTopic, cfg.KafkaGroupID+"-dlq-redrive")
+	adminHandler := handler.NewAdminHandler(webhooks, dlq, cfg)
+	eventsHandler := handler.NewEventsHandler(events, cfg)
+
+	trackerState := filepath.Join(cfg.StateDir, "ack_tracker.json")
+	reassemblerState := filepath.Join(cfg.StateDir, "reassembler.json")
+	fanoutState := filepath.Join(cfg.StateDir, "fanout.json")
+	if err := tracker.LoadState(trackerState); err != nil {
+		log.Printf("[System] Failed to restore AckTracker state: %v", err)
+	}
+	if err := reassembler.LoadState(reassemblerState); err != nil {
+		log.Printf("[System] Failed to restore Reassembler state: %v", err)
+	}
+	if err := fanout.LoadState(fanoutState); err != nil {
+		log.Printf("[System] Failed to restore FanOut state: %v", err)
+	}
+	// Журнал сборки позволяет узлу назначения принимать ответственность за сегменты
+	if cfg.CustodyTransfer {
+		if err := reassembler.OpenJournal(filepath.Join(cfg.StateDir, "reassembly.journal")); err != nil {
+			log.Printf("[WARN] Failed to open reassembly journal, this node will not accept custody as destination: %v", err)
+		}
+	}
+
+	metrics.RegisterGauges(
+		func() float64 { return float64(tracker.Len()) },
+		func() float64 { m, _ := reassembler.Stats(); return float64(m) },
+		func() float64 { _, s := reassembler.Stats(); return float64(s) },
+	)
 
 	// Маршруты
 	router := mux.NewRouter()
-	router.HandleFunc("/sendMessage", handler.SendMessage).Methods("POST")
-	router.HandleFunc("/transferSegment", handler.TransferSegment).Methods("POST")
-	router.HandleFunc("/transferAck", handler.TransferAck).Methods("POST")
+	router.HandleFunc("/sendMessage", transportHandler.SendMessage).Methods("POST")
+	router.HandleFunc("/transferSegment", transportHandler.TransferSegment).Methods("POST")
+	router.HandleFunc("/transferAck", transportHandler.TransferAck).Methods("POST")
+	router.HandleFunc("/messages/{messageId}", transportHandler.CancelMessage).Methods("DELETE")
+	router.HandleFunc("/outbox", transportHandler.OutboxStatus).Methods("GET")
+	// Поток событий раскрывает идентификаторы и отправителей всех сообщений
+	router.HandleFunc("/events", adminHandler.AuthorizeStream(eventsHandler.Stream)).Methods("GET")
+	router.HandleFunc("/events/ws", adminHandler.AuthorizeStream(eventsHandler.WebSocket)).Methods("GET")
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(adminHandler.Authorize)
+	admin.HandleFunc("/webhooks", adminHandler.RegisterWebhook).Methods("POST")
+	admin.HandleFunc("/webhooks", adminHandler.ListWebhooks).Methods("GET")
+	admin.HandleFunc("/webhooks", adminHandler.DeleteWebhook).Methods("DELETE")
+	admin.HandleFunc("/dlq/redrive", adminHandler.RedriveDLQ).Methods("POST")
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Фоновые циклы живут до окончания дренажа HTTP, а не до сигнала
+	workCtx, stopWork := context.WithCancel(context.Background())
+	defer stopWork()
+	var workers sync.WaitGroup
+
+	// Consumer-ы основного и retry-топиков
+	consumer := forward.NewConsumer(segmentQueue, cfg.KafkaSegmentTopic, cfg.KafkaGroupID, cfg, contacts, cgr, signer)
+	retryConsumer := forward.NewRetryConsumer(segmentQueue, cfg.KafkaGroupID+"-retry", cfg, contacts, cgr, signer)
+
+	// Проверки готовности
+	probeClient := &http.Client{Timeout: cfg.ReadinessTimeout}
+	checker := health.NewChecker(cfg.ReadinessTimeout)
+	checker.Register("queue", segmentQueue.Ping)
+	checker.Register("consumer_progress", func(context.Context) error {
+		return consumer.CheckProgress(cfg.MaxConsumerLag, cfg.ConsumerStallTimeout)
+	})
+	checker.Register("channel", health.HTTPReachable(probeClient, cfg.ChannelURL))
+	for _, node := range cfg.AppNodes() {
+		checker.Register("app_"+node, health.HTTPReachable(probeClient, cfg.AppEndpoints[node]))
+	}
+	checker.Register("outbox_store", func(context.Context) error { return outbox.Check() })
+	checker.Register("webhook_outbox_store", func(context.Context) error { return webhookOutbox.Check() })
+	checker.Register("accepting", func(context.Context) error {
+		if transportHandler.Draining() {
+			return errors.New("shutting down")
+		}
+		return nil
+	})
+
+	healthHandler := handler.NewHealthHandler(checker)
+	router.HandleFunc("/healthz", healthHandler.Healthz).Methods("GET")
+	router.HandleFunc("/readyz", healthHandler.Readyz).Methods("GET")
+
+	// Доставка финальных ACK с повторами
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		outbox.Run(workCtx)
+	}()
+
+	// Доставка событий webhook
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		webhookOutbox.Run(workCtx)
+	}()
+
+	// Обновление плана сеансов связи
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		contactPlan.Run(workCtx, cfg.ContactPlanRefresh)
+	}()
+
+	// Обновление графа контактов
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		cgr.Run(workCtx, cfg.ContactPlanRefresh)
+	}()
 
-	// Kafka consumer
-	ctx := context.Background()
-	consumer := kafka.NewConsumer(strings.Split(cfg.KafkaBrokers, ","), "segment-topic", "transport-group", cfg)
+	// Перечитывание ключей для ротации
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		keys.Run(workCtx, cfg.KeyringRefresh)
+	}()
+
+	// Повторная отправка сегментов в порядке срочности
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		tracker.RunResends(workCtx, segmentQueue)
+	}()
 
+	workers.Add(1)
 	go func() {
-		log.Println("[Kafka] Starting consumer...")
-		consumer.Start(ctx)
+		defer workers.Done()
+		log.Println("[Consumer] Starting consumer...")
+		consumer.Start(workCtx)
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		log.Println("[Consumer] Starting retry consumer...")
+		retryConsumer.Start(workCtx)
 	}()
 
 	// Периодическая сборка сообщений
+	workers.Add(1)
 	go func() {
+		defer workers.Done()
 		ticker := time.NewTicker(cfg.CheckInterval)
 		defer ticker.Stop()
 
@@ -57,7 +228,7 @@ func main() {
 			select {
 			case <-ticker.C:
 				reassembler.CheckTimeoutsAndAssemble()
-			case <-ctx.Done():
+			case <-workCtx.Done():
 				log.Println("[System] Shutting down ticker")
 				return
 			}
@@ -66,8 +237,88 @@ func main() {
 
 	// Запуск HTTP сервера
 	addr := ":" + cfg.TransportPort
-	log.Printf("[HTTP] Transport service listening on %s (public)", addr)
-	if err := http.ListenAndServe("0.0.0.0"+addr, router); err != nil {
-		log.Fatalf("[FATAL] HTTP server error: %v", err)
+	server := &http.Server{
+		Addr:    "0.0.0.0" + addr,
+		Handler: router,
+	}
+	// SSE-потоки никогда не становятся idle, закрываем их явно
+	server.RegisterOnShutdown(events.Close)
+
+	go func() {
+		log.Printf("[HTTP] Transport service listening on %s (public)", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("[FATAL] HTTP server error: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Printf("[System] Shutdown signal received, draining (deadline %v)", cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		transportHandler.StopAccepting()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("[HTTP] Shutdown error: %v", err)
+		}
+		log.Println("[HTTP] Server stopped")
+
+		stopWork()
+		workers.Wait()
+
+		if err := consumer.Close(); err != nil {
+			log.Printf("[Consumer] Failed to close consumer: %v", err)
+		}
+		if err := retryConsumer.Close(); err != nil {
+			log.Printf("[Consumer] Failed to close retry consumer: %v", err)
+		}
+		if err := segmentQueue.Close(); err != nil {
+			log.Printf("[Queue] Failed to close %s queue: %v", cfg.QueueBackend, err)
+		}
+
+		if err := tracker.SaveState(trackerState); err != nil {
+			log.Printf("[System] Failed to save AckTracker state: %v", err)
+		}
+		if err := reassembler.SaveState(reassemblerState); err != nil {
+			log.Printf("[System] Failed to save Reassembler state: %v", err)
+		}
+		if err := fanout.SaveState(fanoutState); err != nil {
+			log.Printf("[System] Failed to save FanOut state: %v", err)
+		}
+	}()
+
+	select {
+	case <-done:
+		log.Println("[System] Shutdown complete")
+	case <-shutdownCtx.Done():
+		log.Printf("[System] Shutdown deadline %v exceeded, exiting", cfg.ShutdownTimeout)
+		os.Exit(1)
+	}
+}
+
+// newSegmentQueue создаёт очередь сегментов, выбранную QUEUE_BACKEND.
+func newSegmentQueue(cfg *config.Config) queue.SegmentQueue {
+	switch cfg.QueueBackend {
+	case "memory":
+		log.Println("[Queue] Using in-memory queue: segments are lost on restart")
+		return queue.NewMemory(cfg.KafkaSegmentTopic, cfg.WireFormat)
+	case "file":
+		q, err := queue.NewFile(cfg.QueueDir, cfg.KafkaSegmentTopic, cfg.WireFormat)
+		if err != nil {
+			log.Fatalf("[FATAL] File queue in %s: %v", cfg.QueueDir, err)
+		}
+		log.Printf("[Queue] Using file queue in %s", cfg.QueueDir)
+		return q
+	default:
+		client, err := kafka.NewClient(cfg)
+		if err != nil {
+			log.Fatalf("[FATAL] Kafka client configuration: %v", err)
+		}
+		return kafka.NewQueue(client, cfg.KafkaSegmentTopic, cfg.WireFormat)
 	}
 }
@@ -0,0 +1,224 @@
+// Package auth подписывает сегменты и ACK, уходящие в канал, и проверяет
+// подписи входящих. Подпись ставит каждый узел на своём участке пути
+// (HMAC-SHA256 на заранее распределённом ключе узла), поэтому ретранслятор,
+// меняющий кадр, подписывает его заново. Повторно принятые и слишком
+// старые кадры отклоняются. Кадр считается принятым только после вызова
+// Consume: кадр, который узел не смог обработать, канал может повторить.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"transport/internal/model"
+	"transport/internal/wire"
+)
+
+var (
+	ErrUnsigned     = errors.New("frame is not signed")
+	ErrUnknownPeer  = errors.New("unknown signer")
+	ErrBadSignature = errors.New("invalid signature")
+	ErrStale        = errors.New("signature outside replay window")
+	ErrReplay       = errors.New("frame already received")
+)
+
+// Authenticator подписывает кадры ключом этого узла и проверяет кадры
+// соседей по их ключам. Без ключей подписи не ставятся и не проверяются.
+type Authenticator struct {
+	nodeID string
+	keys   map[string][]byte
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// New создаёт Authenticator. keys — ключи узлов по идентификатору, включая
+// ключ самого узла nodeID; window — допустимое расхождение момента подписи
+// с текущим временем, в течение которого помнятся принятые подписи.
+func New(nodeID string, keys map[string]string, window time.Duration) (*Authenticator, error) {
+	a := &Authenticator{
+		nodeID: nodeID,
+		keys:   make(map[string][]byte, len(keys)),
+		window: window,
+		seen:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+	for node, key := range keys {
+		a.keys[node] = []byte(key)
+	}
+	if a.Enabled() {
+		if _, ok := a.keys[nodeID]; !ok {
+			return nil, fmt.Errorf("no key for this node %q", nodeID)
+		}
+	}
+	return a, nil
+}
+
+// Enabled сообщает, заданы ли ключи.
+func (a *Authenticator) Enabled() bool {
+	return len(a.keys) > 0
+}
+
+// SignSegment подписывает сегмент ключом этого узла.
+func (a *Authenticator) SignSegment(seg model.Segment) (model.Segment, error) {
+	if !a.Enabled() {
+		return seg, nil
+	}
+	seg.Auth = model.Auth{Signer: a.nodeID, SignedAt: a.now().UnixNano()}
+	sig, err := a.segmentMAC(seg, a.keys[a.nodeID])
+	if err != nil {
+		return seg, err
+	}
+	seg.Signature = sig
+	return seg, nil
+}
+
+// VerifySegment проверяет подпись сегмента и отсутствие повтора. После
+// обработки сегмента его подпись нужно отметить через Consume.
+func (a *Authenticator) VerifySegment(seg model.Segment) error {
+	if !a.Enabled() {
+		return nil
+	}
+	signed := seg.Auth
+	key, err := a.check(signed)
+	if err != nil {
+		return err
+	}
+	seg.Signature = ""
+	want, err := a.segmentMAC(seg, key)
+	if err != nil {
+		return err
+	}
+	return a.accept(signed, want)
+}
+
+// SignAck подписывает ACK ключом этого узла.
+func (a *Authenticator) SignAck(ack model.Ack) (model.Ack, error) {
+	if !a.Enabled() {
+		return ack, nil
+	}
+	ack.Auth = model.Auth{Signer: a.nodeID, SignedAt: a.now().UnixNano()}
+	sig, err := a.ackMAC(ack, a.keys[a.nodeID])
+	if err != nil {
+		return ack, err
+	}
+	ack.Signature = sig
+	return ack, nil
+}
+
+// VerifyAck проверяет подпись ACK и отсутствие повтора. После обработки
+// ACK его подпись нужно отметить через Consume.
+func (a *Authenticator) VerifyAck(ack model.Ack) error {
+	if !a.Enabled() {
+		return nil
+	}
+	signed := ack.Auth
+	key, err := a.check(signed)
+	if err != nil {
+		return err
+	}
+	ack.Signature = ""
+	want, err := a.ackMAC(ack, key)
+	if err != nil {
+		return err
+	}
+	return a.accept(signed, want)
+}
+
+// check проверяет наличие подписи, ключ подписавшего узла и момент подписи.
+func (a *Authenticator) check(signed model.Auth) ([]byte, error) {
+	if signed.Signature == "" || signed.Signer == "" {
+		return nil, ErrUnsigned
+	}
+	key, ok := a.keys[signed.Signer]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownPeer, signed.Signer)
+	}
+	age := a.now().Sub(time.Unix(0, signed.SignedAt))
+	if age > a.window || age < -a.window {
+		return nil, fmt.Errorf("%w: signed %v ago", ErrStale, age.Round(time.Second))
+	}
+	return key, nil
+}
+
+// accept сравнивает подпись с ожидаемой и проверяет, что кадр с этой
+// подписью ещё не был принят.
+func (a *Authenticator) accept(signed model.Auth, want string) error {
+	if !hmac.Equal([]byte(signed.Signature), []byte(want)) {
+		return ErrBadSignature
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.prune()
+	if _, dup := a.seen[seenID(signed)]; dup {
+		return ErrReplay
+	}
+	return nil
+}
+
+// Consume отмечает проверенный кадр как принятый: его повтор в пределах
+// окна будет отклонён. Вызывается, когда кадр обработан — сегмент записан
+// в очередь или буфер сборки, ACK учтён.
+func (a *Authenticator) Consume(signed model.Auth) {
+	if !a.Enabled() || signed.Signature == "" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.prune()
+	// Кадр с этой подписью станет устаревшим через window после подписи
+	a.seen[seenID(signed)] = time.Unix(0, signed.SignedAt).Add(a.window)
+}
+
+// prune удаляет подписи, пережившие окно, не чаще раза за окно.
+func (a *Authenticator) prune() {
+	now := a.now()
+	if now.Sub(a.lastPrune) <= a.window {
+		return
+	}
+	for sig, until := range a.seen {
+		if now.After(until) {
+			delete(a.seen, sig)
+		}
+	}
+	a.lastPrune = now
+}
+
+func seenID(signed model.Auth) string {
+	return signed.Signer + "/" + signed.Signature
+}
+
+// segmentMAC считает подпись по бинарному кодированию сегмента без поля
+// подписи: оно одинаково на обоих концах при любом формате кадра в канале.
+func (a *Authenticator) segmentMAC(seg model.Segment, key []byte) (string, error) {
+	data, err := wire.EncodeSegment(wire.FormatBinary, seg)
+	if err != nil {
+		return "", err
+	}
+	return mac(key, data), nil
+}
+
+func (a *Authenticator) ackMAC(ack model.Ack, key []byte) (string, error) {
+	data, err := wire.EncodeAck(wire.FormatBinary, ack)
+	if err != nil {
+		return "", err
+	}
+	return mac(key, data), nil
+}
+
+func mac(key, data []byte) string {
+	m := hmac.New(sha256.New, key)
+	m.Write(data)
+	return hex.EncodeToString(m.Sum(nil))
+}
@@ -0,0 +1,475 @@
+// Package bundle реализует кодирование Bundle Protocol v7 (RFC 9171):
+// первичный блок, канонические блоки и CRC, — чтобы транспорт мог
+// обмениваться сегментами и ACK с DTN-узлами.
+package bundle
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sync/atomic"
+	"time"
+)
+
+const Version = 7
+
+// ContentType — тип тела HTTP-запроса с бандлом.
+const ContentType = "application/vnd.bpv7+cbor"
+
+// Флаги обработки бандла (RFC 9171, 4.2.3)
+const (
+	FlagIsFragment      uint64 = 0x000001
+	FlagAdminRecord     uint64 = 0x000002
+	FlagMustNotFragment uint64 = 0x000004
+	FlagAppAckRequested uint64 = 0x000020
+)
+
+// BlockTypePayload — код типа блока полезной нагрузки.
+const BlockTypePayload uint64 = 1
+
+// CRCType — тип CRC блока (RFC 9171, 4.2.1).
+type CRCType uint64
+
+const (
+	CRCNone   CRCType = 0
+	CRC16X25  CRCType = 1
+	CRC32CSum CRCType = 2
+)
+
+func ParseCRCType(s string) (CRCType, error) {
+	switch s {
+	case "none":
+		return CRCNone, nil
+	case "crc16":
+		return CRC16X25, nil
+	case "crc32c":
+		return CRC32CSum, nil
+	}
+	return 0, fmt.Errorf("unknown CRC type %q (expected none, crc16 or crc32c)", s)
+}
+
+func (t CRCType) String() string {
+	switch t {
+	case CRCNone:
+		return "none"
+	case CRC16X25:
+		return "crc16"
+	case CRC32CSum:
+		return "crc32c"
+	}
+	return fmt.Sprintf("crc-%d", uint64(t))
+}
+
+func (t CRCType) size() int {
+	switch t {
+	case CRC16X25:
+		return 2
+	case CRC32CSum:
+		return 4
+	}
+	return 0
+}
+
+var (
+	ErrCRCMismatch = errors.New("CRC mismatch")
+	ErrExpired     = errors.New("bundle: lifetime expired")
+	ErrNoPayload   = errors.New("bundle: no payload block")
+)
+
+// dtnEpoch — начало отсчёта DTN time: 2000-01-01 00:00:00 UTC.
+var dtnEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// PrimaryBlock — первичный блок бандла. Фрагментация не поддерживается:
+// сегменты транспорта заведомо меньше любого разумного MTU канала.
+type PrimaryBlock struct {
+	Flags       uint64
+	CRC         CRCType
+	Destination EID
+	Source      EID
+	ReportTo    EID
+	// CreationTime нулевое, если у узла нет точных часов (DTN time 0)
+	CreationTime time.Time
+	Sequence     uint64
+	Lifetime     time.Duration
+}
+
+// Block — канонический блок бандла.
+type Block struct {
+	Type   uint64
+	Number uint64
+	Flags  uint64
+	CRC    CRCType
+	Data   []byte
+}
+
+type Bundle struct {
+	Primary PrimaryBlock
+	// Blocks — канонические блоки; блок полезной нагрузки идёт последним
+	Blocks []Block
+}
+
+// Payload возвращает данные блока полезной нагрузки.
+func (b *Bundle) Payload() ([]byte, error) {
+	for _, blk := range b.Blocks {
+		if blk.Type == BlockTypePayload {
+			return blk.Data, nil
+		}
+	}
+	return nil, ErrNoPayload
+}
+
+// Expired сообщает, истёк ли срок жизни бандла к моменту now. Бандлы без
+// времени создания по этому правилу не устаревают.
+func (b *Bundle) Expired(now time.Time) bool {
+	if b.Primary.CreationTime.IsZero() {
+		return false
+	}
+	return now.After(b.Primary.CreationTime.Add(b.Primary.Lifetime))
+}
+
+// Options — параметры бандлов, которые создаёт этот узел. Source — EID
+// этого узла Node, Destination — EID назначения данных без маршрутизации.
+// NodeEIDs задаёт EID остальных узлов; узел без записи получает
+// dtn://<узел>/transport.
+type Options struct {
+	Source      EID
+	Destination EID
+	Lifetime    time.Duration
+	CRC         CRCType
+	Node        string
+	NodeEIDs    map[string]EID
+}
+
+// EIDFor возвращает EID узла node; пустой node — fallback.
+func (o Options) EIDFor(node string, fallback EID) EID {
+	switch {
+	case node == "":
+		return fallback
+	case node == o.Node:
+		return o.Source
+	}
+	if eid, ok := o.NodeEIDs[node]; ok {
+		return eid
+	}
+	return EID{Scheme: SchemeDTN, SSP: "//" + node + "/transport"}
+}
+
+// sequence различает бандлы одного источника с одинаковым временем создания
+var sequence atomic.Uint64
+
+// New создаёт бандл с одним блоком полезной нагрузки adu.
+func New(opts Options, adu []byte) *Bundle {
+	return &Bundle{
+		Primary: PrimaryBlock{
+			Flags:        FlagMustNotFragment,
+			CRC:          opts.CRC,
+			Destination:  opts.Destination,
+			Source:       opts.Source,
+			ReportTo:     opts.Source,
+			CreationTime: time.Now().UTC().Truncate(time.Millisecond),
+			Sequence:     sequence.Add(1) - 1,
+			Lifetime:     opts.Lifetime,
+		},
+		Blocks: []Block{{
+			Type:   BlockTypePayload,
+			Number: 1,
+			CRC:    opts.CRC,
+			Data:   adu,
+		}},
+	}
+}
+
+// Encode сериализует бандл: CBOR-массив неопределённой длины из первичного
+// и канонических блоков.
+func Encode(b *Bundle) ([]byte, error) {
+	if n := len(b.Blocks); n == 0 || b.Blocks[n-1].Type != BlockTypePayload {
+		return nil, errors.New("bundle: payload block must be last")
+	}
+	if b.Primary.Flags&FlagIsFragment != 0 {
+		return nil, errors.New("bundle: fragments are not supported")
+	}
+
+	out := []byte{cborIndefiniteArray}
+	out = appendPrimary(out, &b.Primary)
+	for i := range b.Blocks {
+		out = appendBlock(out, &b.Blocks[i])
+	}
+	return append(out, cborBreak), nil
+}
+
+func appendPrimary(out []byte, p *PrimaryBlock) []byte {
+	start := len(out)
+	n := 8
+	if p.CRC != CRCNone {
+		n++
+	}
+
+	out = appendArray(out, n)
+	out = appendUint(out, Version)
+	out = appendUint(out, p.Flags)
+	out = appendUint(out, uint64(p.CRC))
+	out = appendEID(out, p.Destination)
+	out = appendEID(out, p.Source)
+	out = appendEID(out, p.ReportTo)
+	out = appendArray(out, 2)
+	out = appendUint(out, dtnTime(p.CreationTime))
+	out = appendUint(out, p.Sequence)
+	out = appendUint(out, uint64(p.Lifetime/time.Millisecond))
+	return appendCRC(out, start, p.CRC)
+}
+
+func appendBlock(out []byte, blk *Block) []byte {
+	start := len(out)
+	n := 5
+	if blk.CRC != CRCNone {
+		n++
+	}
+
+	out = appendArray(out, n)
+	out = appendUint(out, blk.Type)
+	out = appendUint(out, blk.Number)
+	out = appendUint(out, blk.Flags)
+	out = appendUint(out, uint64(blk.CRC))
+	out = appendBytes(out, blk.Data)
+	return appendCRC(out, start, blk.CRC)
+}
+
+// appendCRC дописывает последний элемент блока — CRC. Значение считается
+// по всему блоку, в котором поле CRC заполнено нулями (RFC 9171, 4.2.1).
+func appendCRC(out []byte, start int, t CRCType) []byte {
+	size := t.size()
+	if size == 0 {
+		return out
+	}
+	out = appendBytes(out, make([]byte, size))
+	putCRC(out[len(out)-size:], t, checksum(t, out[start:]))
+	return out
+}
+
+func checksum(t CRCType, data []byte) uint32 {
+	if t == CRC16X25 {
+		return uint32(crc16X25(data))
+	}
+	return crc32.Checksum(data, castagnoli)
+}
+
+func putCRC(dst []byte, t CRCType, v uint32) {
+	if t == CRC16X25 {
+		binary.BigEndian.PutUint16(dst, uint16(v))
+		return
+	}
+	binary.BigEndian.PutUint32(dst, v)
+}
+
+// verifyCRC проверяет CRC блока raw, последние size байт которого — значение CRC.
+func verifyCRC(raw []byte, t CRCType) error {
+	size := t.size()
+	want := make([]byte, size)
+	copy(want, raw[len(raw)-size:])
+
+	zeroed := make([]byte, len(raw))
+	copy(zeroed, raw)
+	clear(zeroed[len(zeroed)-size:])
+
+	got := make([]byte, size)
+	putCRC(got, t, checksum(t, zeroed))
+	if string(got) != string(want) {
+		return ErrCRCMismatch
+	}
+	return nil
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// crc16X25 — CRC-16/X.25: полином 0x1021 (отражённый 0x8408), init и xorout 0xFFFF.
+func crc16X25(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, c := range data {
+		crc ^= uint16(c)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0x8408
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return ^crc
+}
+
+func dtnTime(t time.Time) uint64 {
+	if t.IsZero() || t.Before(dtnEpoch) {
+		return 0
+	}
+	return uint64(t.Sub(dtnEpoch) / time.Millisecond)
+}
+
+func fromDTNTime(ms uint64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return dtnEpoch.Add(time.Duration(ms) * time.Millisecond)
+}
+
+// Decode разбирает бандл и проверяет CRC всех блоков.
+func Decode(data []byte) (*Bundle, error) {
+	r := &cborReader{data: data}
+	if c, err := r.peek(); err != nil || c != cborIndefiniteArray {
+		return nil, errors.New("bundle: expected indefinite-length array")
+	}
+	r.pos++
+
+	b := &Bundle{}
+	if err := readPrimary(r, &b.Primary); err != nil {
+		return nil, fmt.Errorf("bundle: primary block: %w", err)
+	}
+
+	for {
+		c, err := r.peek()
+		if err != nil {
+			return nil, fmt.Errorf("bundle: %w", err)
+		}
+		if c == cborBreak {
+			r.pos++
+			break
+		}
+		blk, err := readBlock(r)
+		if err != nil {
+			return nil, fmt.Errorf("bundle: block %d: %w", len(b.Blocks)+1, err)
+		}
+		b.Blocks = append(b.Blocks, blk)
+	}
+
+	if r.pos != len(data) {
+		return nil, errors.New("bundle: trailing data after break")
+	}
+	if n := len(b.Blocks); n == 0 || b.Blocks[n-1].Type != BlockTypePayload {
+		return nil, ErrNoPayload
+	}
+	return b, nil
+}
+
+func readPrimary(r *cborReader, p *PrimaryBlock) error {
+	start := r.pos
+	n, err := r.array()
+	if err != nil {
+		return err
+	}
+	if n < 8 {
+		return fmt.Errorf("expected at least 8 elements, got %d", n)
+	}
+
+	version, err := r.uint()
+	if err != nil {
+		return err
+	}
+	if version != Version {
+		return fmt.Errorf("unsupported version %d", version)
+	}
+	if p.Flags, err = r.uint(); err != nil {
+		return err
+	}
+	if p.Flags&FlagIsFragment != 0 {
+		return errors.New("fragments are not supported")
+	}
+	crcType, err := r.uint()
+	if err != nil {
+		return err
+	}
+	p.CRC = CRCType(crcType)
+	if p.CRC > CRC32CSum {
+		return fmt.Errorf("unsupported CRC type %d", crcType)
+	}
+
+	want := 8
+	if p.CRC != CRCNone {
+		want++
+	}
+	if n != want {
+		return fmt.Errorf("expected %d elements, got %d", want, n)
+	}
+
+	if p.Destination, err = readEID(r); err != nil {
+		return err
+	}
+	if p.Source, err = readEID(r); err != nil {
+		return err
+	}
+	if p.ReportTo, err = readEID(r); err != nil {
+		return err
+	}
+
+	if n, err := r.array(); err != nil || n != 2 {
+		return errors.New("invalid creation timestamp")
+	}
+	created, err := r.uint()
+	if err != nil {
+		return err
+	}
+	p.CreationTime = fromDTNTime(created)
+	if p.Sequence, err = r.uint(); err != nil {
+		return err
+	}
+	lifetime, err := r.uint()
+	if err != nil {
+		return err
+	}
+	p.Lifetime = time.Duration(lifetime) * time.Millisecond
+
+	return readCRC(r, start, p.CRC)
+}
+
+func readBlock(r *cborReader) (Block, error) {
+	var blk Block
+	start := r.pos
+	n, err := r.array()
+	if err != nil {
+		return blk, err
+	}
+
+	if blk.Type, err = r.uint(); err != nil {
+		return blk, err
+	}
+	if blk.Number, err = r.uint(); err != nil {
+		return blk, err
+	}
+	if blk.Flags, err = r.uint(); err != nil {
+		return blk, err
+	}
+	crcType, err := r.uint()
+	if err != nil {
+		return blk, err
+	}
+	blk.CRC = CRCType(crcType)
+	if blk.CRC > CRC32CSum {
+		return blk, fmt.Errorf("unsupported CRC type %d", crcType)
+	}
+
+	want := 5
+	if blk.CRC != CRCNone {
+		want++
+	}
+	if n != want {
+		return blk, fmt.Errorf("expected %d elements, got %d", want, n)
+	}
+
+	if blk.Data, err = r.bytes(); err != nil {
+		return blk, err
+	}
+	return blk, readCRC(r, start, blk.CRC)
+}
+
+func readCRC(r *cborReader, start int, t CRCType) error {
+	if t == CRCNone {
+		return nil
+	}
+	v, err := r.bytes()
+	if err != nil {
+		return err
+	}
+	if len(v) != t.size() {
+		return fmt.Errorf("CRC value: expected %d bytes, got %d", t.size(), len(v))
+	}
+	return verifyCRC(r.data[start:r.pos], t)
+}
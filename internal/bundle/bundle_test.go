@@ -0,0 +1,237 @@
+package bundle
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"transport/internal/model"
+)
+
+// Векторы закодированы вручную по CDDL RFC 9171 (приложение B): бандл
+// ipn:1.1 → ipn:2.1 без точного времени создания, со временем жизни 1 ч и
+// полезной нагрузкой "hello". CRC посчитаны независимой реализацией.
+var vectors = []struct {
+	name string
+	crc  CRCType
+	hex  string
+}{
+	{"no CRC", CRCNone, "9f880704008202820201820282010182028201018200001a0036ee8085010100004568656c6c6fff"},
+	{"CRC-16/X.25", CRC16X25, "9f890704018202820201820282010182028201018200001a0036ee8042422586010100014568656c6c6f424bf3ff"},
+	{"CRC-32C", CRC32CSum, "9f890704028202820201820282010182028201018200001a0036ee80448fcfba5b86010100024568656c6c6f4421c13f2fff"},
+}
+
+func vectorBundle(crc CRCType) *Bundle {
+	return &Bundle{
+		Primary: PrimaryBlock{
+			Flags:       FlagMustNotFragment,
+			CRC:         crc,
+			Destination: EID{Scheme: SchemeIPN, Node: 2, Service: 1},
+			Source:      EID{Scheme: SchemeIPN, Node: 1, Service: 1},
+			ReportTo:    EID{Scheme: SchemeIPN, Node: 1, Service: 1},
+			Lifetime:    time.Hour,
+		},
+		Blocks: []Block{{Type: BlockTypePayload, Number: 1, CRC: crc, Data: []byte("hello")}},
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Контрольные значения CRC для строки "123456789" из каталога параметров CRC
+func TestCRCCheckValues(t *testing.T) {
+	check := []byte("123456789")
+	if got := crc16X25(check); got != 0x906e {
+		t.Errorf("CRC-16/X.25 = %#04x, want 0x906e", got)
+	}
+	if got := crc32.Checksum(check, castagnoli); got != 0xe3069283 {
+		t.Errorf("CRC-32C = %#08x, want 0xe3069283", got)
+	}
+}
+
+func TestEncodeVectors(t *testing.T) {
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			got, err := Encode(vectorBundle(v.crc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := mustHex(t, v.hex); !bytes.Equal(got, want) {
+				t.Errorf("Encode =\n%x\nwant\n%x", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeVectors(t *testing.T) {
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			got, err := Decode(mustHex(t, v.hex))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := vectorBundle(v.crc); !reflect.DeepEqual(got, want) {
+				t.Errorf("Decode = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestEIDEncoding(t *testing.T) {
+	tests := []struct {
+		eid string
+		hex string
+	}{
+		{"dtn:none", "820100"},
+		{"dtn://earth/transport", "820171" + hex.EncodeToString([]byte("//earth/transport"))},
+		{"ipn:977000.1", "8202821a000ee86801"},
+	}
+	for _, tt := range tests {
+		e, err := ParseEID(tt.eid)
+		if err != nil {
+			t.Fatalf("ParseEID(%q): %v", tt.eid, err)
+		}
+		if e.String() != tt.eid {
+			t.Errorf("String() = %q, want %q", e.String(), tt.eid)
+		}
+		got := appendEID(nil, e)
+		if want := mustHex(t, tt.hex); !bytes.Equal(got, want) {
+			t.Errorf("%s encodes to %x, want %x", tt.eid, got, want)
+		}
+		back, err := readEID(&cborReader{data: got})
+		if err != nil || back != e {
+			t.Errorf("%s decodes to %+v, %v", tt.eid, back, err)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, crc := range []CRCType{CRCNone, CRC16X25, CRC32CSum} {
+		t.Run(crc.String(), func(t *testing.T) {
+			opts := Options{
+				Source:      EID{Scheme: SchemeDTN, SSP: "//earth/transport"},
+				Destination: EID{Scheme: SchemeIPN, Node: 4, Service: 7},
+				Lifetime:    90 * time.Minute,
+				CRC:         crc,
+			}
+			adu := bytes.Repeat([]byte{0x4d, 0x4c, 0x00, 0xff}, 100)
+			b := New(opts, adu)
+
+			data, err := Encode(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, b) {
+				t.Errorf("round trip = %+v, want %+v", got, b)
+			}
+			payload, err := got.Payload()
+			if err != nil || !bytes.Equal(payload, adu) {
+				t.Errorf("Payload() = %x, %v", payload, err)
+			}
+		})
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	crc16 := vectors[1].hex
+	tests := []struct {
+		name string
+		hex  string
+		want string
+	}{
+		// Последний байт "hello" заменён: CRC блока полезной нагрузки не сходится
+		{"bad CRC", strings.Replace(crc16, "68656c6c6f", "68656c6c6e", 1), ErrCRCMismatch.Error()},
+		// Флаги 0x05: must-not-fragment и is-fragment
+		{"fragment", strings.Replace(vectors[0].hex, "9f88070400", "9f88070500", 1), "fragments are not supported"},
+		{"trailing bytes", crc16 + "00", "trailing data"},
+		{"truncated", crc16[:len(crc16)-4], "unexpected end of data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(mustHex(t, tt.hex))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Decode error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeRejectsFragment(t *testing.T) {
+	b := vectorBundle(CRCNone)
+	b.Primary.Flags |= FlagIsFragment
+	if _, err := Encode(b); err == nil {
+		t.Fatal("Encode accepted a fragment")
+	}
+}
+
+func TestUnwrapExpired(t *testing.T) {
+	b := New(Options{Source: NoneEID, Destination: NoneEID, Lifetime: time.Minute}, []byte("adu"))
+	data, err := Encode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = Unwrap(data, b.Primary.CreationTime.Add(2*time.Minute))
+	if !errors.Is(err, ErrExpired) {
+		t.Fatalf("Unwrap error = %v, want ErrExpired", err)
+	}
+}
+
+// EID бандла берутся из отправителя и получателя кадра, а не из настроек
+// узла: ретранслятор и ACK должны адресовать настоящие концы маршрута
+func TestWrapEIDs(t *testing.T) {
+	opts := Options{
+		Source:      EID{Scheme: SchemeDTN, SSP: "//mro/transport"},
+		Destination: EID{Scheme: SchemeDTN, SSP: "//mars/transport"},
+		Lifetime:    time.Hour,
+		Node:        "mro",
+		NodeEIDs:    map[string]EID{"earth": {Scheme: SchemeDTN, SSP: "//dsn/earth"}},
+	}
+	eids := func(data []byte, err error) (string, string) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, b, err := Unwrap(data, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b.Primary.Source.String(), b.Primary.Destination.String()
+	}
+
+	tests := []struct {
+		name     string
+		data     func() ([]byte, error)
+		src, dst string
+	}{
+		{"relayed segment", func() ([]byte, error) {
+			return WrapSegment(opts, model.Segment{MessageID: "m", TotalSegments: 1, Source: "earth", Destination: "europa"})
+		}, "dtn://dsn/earth", "dtn://europa/transport"},
+		{"segment without route", func() ([]byte, error) {
+			return WrapSegment(opts, model.Segment{MessageID: "m", TotalSegments: 1})
+		}, "dtn://mro/transport", "dtn://mars/transport"},
+		{"ack", func() ([]byte, error) {
+			return WrapAck(opts, model.Ack{MessageID: "m", Destination: "earth"})
+		}, "dtn://mro/transport", "dtn://dsn/earth"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if src, dst := eids(tt.data()); src != tt.src || dst != tt.dst {
+				t.Fatalf("EIDs = %s -> %s, want %s -> %s", src, dst, tt.src, tt.dst)
+			}
+		})
+	}
+}
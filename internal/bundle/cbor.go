@@ -0,0 +1,149 @@
+package bundle
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Минимальное подмножество CBOR (RFC 8949), достаточное для BPv7:
+// целые без знака, байтовые и текстовые строки, массивы (в том числе
+// неопределённой длины) и break.
+
+const (
+	majorUint  = 0
+	majorBytes = 2
+	majorText  = 3
+	majorArray = 4
+)
+
+const (
+	cborIndefiniteArray = 0x9f
+	cborBreak           = 0xff
+)
+
+var errTruncated = errors.New("cbor: unexpected end of data")
+
+func appendHead(b []byte, major byte, v uint64) []byte {
+	m := major << 5
+	switch {
+	case v < 24:
+		return append(b, m|byte(v))
+	case v <= 0xff:
+		return append(b, m|24, byte(v))
+	case v <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, m|25), uint16(v))
+	case v <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, m|26), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(b, m|27), v)
+}
+
+func appendUint(b []byte, v uint64) []byte { return appendHead(b, majorUint, v) }
+
+func appendArray(b []byte, n int) []byte { return appendHead(b, majorArray, uint64(n)) }
+
+func appendBytes(b []byte, v []byte) []byte {
+	return append(appendHead(b, majorBytes, uint64(len(v))), v...)
+}
+
+func appendText(b []byte, v string) []byte {
+	return append(appendHead(b, majorText, uint64(len(v))), v...)
+}
+
+type cborReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *cborReader) peek() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errTruncated
+	}
+	return r.data[r.pos], nil
+}
+
+// head читает начальный байт и аргумент элемента. Неопределённая длина
+// (additional info 31) здесь не поддерживается — её обрабатывает вызывающий.
+func (r *cborReader) head() (major byte, v uint64, err error) {
+	if r.pos >= len(r.data) {
+		return 0, 0, errTruncated
+	}
+	ib := r.data[r.pos]
+	r.pos++
+	major, info := ib>>5, ib&0x1f
+
+	var n int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		n = 1
+	case info == 25:
+		n = 2
+	case info == 26:
+		n = 4
+	case info == 27:
+		n = 8
+	default:
+		return 0, 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+	}
+	if r.pos+n > len(r.data) {
+		return 0, 0, errTruncated
+	}
+	for _, c := range r.data[r.pos : r.pos+n] {
+		v = v<<8 | uint64(c)
+	}
+	r.pos += n
+	return major, v, nil
+}
+
+func (r *cborReader) expect(major byte) (uint64, error) {
+	m, v, err := r.head()
+	if err != nil {
+		return 0, err
+	}
+	if m != major {
+		return 0, fmt.Errorf("cbor: expected major type %d, got %d", major, m)
+	}
+	return v, nil
+}
+
+func (r *cborReader) uint() (uint64, error) { return r.expect(majorUint) }
+
+func (r *cborReader) array() (int, error) {
+	n, err := r.expect(majorArray)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(r.data)-r.pos) {
+		return 0, errTruncated
+	}
+	return int(n), nil
+}
+
+func (r *cborReader) bytes() ([]byte, error) {
+	n, err := r.expect(majorBytes)
+	if err != nil {
+		return nil, err
+	}
+	return r.take(n)
+}
+
+func (r *cborReader) text() (string, error) {
+	n, err := r.expect(majorText)
+	if err != nil {
+		return "", err
+	}
+	b, err := r.take(n)
+	return string(b), err
+}
+
+func (r *cborReader) take(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errTruncated
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
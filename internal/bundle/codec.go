@@ -0,0 +1,60 @@
+package bundle
+
+import (
+	"fmt"
+	"time"
+	"transport/internal/model"
+	"transport/internal/wire"
+)
+
+// Полезная нагрузка бандла (ADU) — бинарный конверт пакета wire, поэтому
+// тип содержимого (сегмент или ACK) и версия схемы передаются внутри него.
+
+// WrapSegment упаковывает сегмент в бандл от узла-отправителя сообщения к
+// узлу назначения. Время жизни бандла не превышает остаток срока жизни
+// сообщения, чтобы узлы DTN отбрасывали его вовремя.
+func WrapSegment(opts Options, seg model.Segment) ([]byte, error) {
+	adu, err := wire.EncodeSegment(wire.FormatBinary, seg)
+	if err != nil {
+		return nil, err
+	}
+	opts.Source = opts.EIDFor(seg.Source, opts.Source)
+	opts.Destination = opts.EIDFor(seg.Destination, opts.Destination)
+	if seg.ExpiresAt > 0 {
+		if left := time.Until(time.UnixMilli(seg.ExpiresAt)); left < opts.Lifetime {
+			opts.Lifetime = max(left, time.Millisecond)
+		}
+	}
+	return Encode(New(opts, adu))
+}
+
+// WrapAck упаковывает ACK в бандл от этого узла к узлу-отправителю сообщения.
+func WrapAck(opts Options, ack model.Ack) ([]byte, error) {
+	adu, err := wire.EncodeAck(wire.FormatBinary, ack)
+	if err != nil {
+		return nil, err
+	}
+	opts.Destination = opts.EIDFor(ack.Destination, opts.Destination)
+	return Encode(New(opts, adu))
+}
+
+// Unwrap разбирает бандл, отбрасывает просроченный и возвращает его
+// полезную нагрузку в бинарном формате wire.
+func Unwrap(data []byte, now time.Time) ([]byte, *Bundle, error) {
+	b, err := Decode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b.Expired(now) {
+		return nil, b, fmt.Errorf("%w: created %s, lifetime %v",
+			ErrExpired, b.Primary.CreationTime.Format(time.RFC3339), b.Primary.Lifetime)
+	}
+	adu, err := b.Payload()
+	if err != nil {
+		return nil, b, err
+	}
+	if !wire.IsBinary(adu) {
+		return nil, b, fmt.Errorf("bundle: payload from %s is not a transport envelope", b.Primary.Source)
+	}
+	return adu, b, nil
+}
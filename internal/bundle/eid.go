@@ -0,0 +1,127 @@
+package bundle
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Коды схем endpoint ID (RFC 9171, 4.2.5.1)
+const (
+	SchemeDTN uint64 = 1
+	SchemeIPN uint64 = 2
+)
+
+// EID — endpoint ID бандла: dtn:none, dtn://node/service или ipn:node.service.
+type EID struct {
+	Scheme uint64
+	// SSP схемы dtn без префикса "dtn:"; пустая строка — dtn:none
+	SSP     string
+	Node    uint64
+	Service uint64
+}
+
+// NoneEID — нулевой endpoint dtn:none.
+var NoneEID = EID{Scheme: SchemeDTN}
+
+func ParseEID(s string) (EID, error) {
+	switch {
+	case s == "dtn:none":
+		return NoneEID, nil
+	case strings.HasPrefix(s, "dtn://"):
+		if len(s) == len("dtn://") {
+			return EID{}, fmt.Errorf("invalid endpoint ID %q: empty node name", s)
+		}
+		return EID{Scheme: SchemeDTN, SSP: strings.TrimPrefix(s, "dtn:")}, nil
+	case strings.HasPrefix(s, "ipn:"):
+		node, service, ok := strings.Cut(strings.TrimPrefix(s, "ipn:"), ".")
+		if !ok {
+			return EID{}, fmt.Errorf("invalid endpoint ID %q: expected ipn:node.service", s)
+		}
+		n, err := strconv.ParseUint(node, 10, 64)
+		if err != nil {
+			return EID{}, fmt.Errorf("invalid endpoint ID %q: %v", s, err)
+		}
+		svc, err := strconv.ParseUint(service, 10, 64)
+		if err != nil {
+			return EID{}, fmt.Errorf("invalid endpoint ID %q: %v", s, err)
+		}
+		return EID{Scheme: SchemeIPN, Node: n, Service: svc}, nil
+	}
+	return EID{}, fmt.Errorf("invalid endpoint ID %q: expected dtn: or ipn: scheme", s)
+}
+
+func (e EID) String() string {
+	switch e.Scheme {
+	case SchemeDTN:
+		if e.SSP == "" {
+			return "dtn:none"
+		}
+		return "dtn:" + e.SSP
+	case SchemeIPN:
+		return fmt.Sprintf("ipn:%d.%d", e.Node, e.Service)
+	}
+	return fmt.Sprintf("unknown-scheme-%d", e.Scheme)
+}
+
+func appendEID(b []byte, e EID) []byte {
+	b = appendArray(b, 2)
+	b = appendUint(b, e.Scheme)
+	switch e.Scheme {
+	case SchemeIPN:
+		b = appendArray(b, 2)
+		b = appendUint(b, e.Node)
+		return appendUint(b, e.Service)
+	default:
+		if e.SSP == "" {
+			return appendUint(b, 0)
+		}
+		return appendText(b, e.SSP)
+	}
+}
+
+func readEID(r *cborReader) (EID, error) {
+	n, err := r.array()
+	if err != nil {
+		return EID{}, err
+	}
+	if n != 2 {
+		return EID{}, fmt.Errorf("endpoint ID: expected 2 elements, got %d", n)
+	}
+	scheme, err := r.uint()
+	if err != nil {
+		return EID{}, err
+	}
+
+	e := EID{Scheme: scheme}
+	switch scheme {
+	case SchemeDTN:
+		c, err := r.peek()
+		if err != nil {
+			return EID{}, err
+		}
+		if c>>5 == majorUint {
+			// dtn:none кодируется целым 0
+			if v, err := r.uint(); err != nil || v != 0 {
+				return EID{}, fmt.Errorf("endpoint ID: invalid dtn SSP")
+			}
+			return e, nil
+		}
+		if e.SSP, err = r.text(); err != nil {
+			return EID{}, err
+		}
+	case SchemeIPN:
+		if n, err := r.array(); err != nil || n != 2 {
+			return EID{}, fmt.Errorf("endpoint ID: invalid ipn SSP")
+		}
+		if e.Node, err = r.uint(); err != nil {
+			return EID{}, err
+		}
+		if e.Service, err = r.uint(); err != nil {
+			return EID{}, err
+		}
+	default:
+		return EID{}, fmt.Errorf("endpoint ID: unsupported scheme %d", scheme)
+	}
+	return e, nil
+}
@@ -0,0 +1,203 @@
+package ccsds
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"transport/internal/model"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Packet
+		hex  string
+	}{
+		// Версия 000, тип 0, без вторичного заголовка, APID 100; unsegmented, счётчик 5; длина 3-1
+		{"telemetry", Packet{Type: TypeTelemetry, APID: 100, SequenceFlags: SeqUnsegmented, SequenceCount: 5, Data: []byte("abc")}, "0064c0050002616263"},
+		// Тип 1, вторичный заголовок, APID 0x7fe; first, счётчик 0x3fff
+		{"telecommand", Packet{Type: TypeTelecommand, SecondaryHeader: true, APID: MaxAPID, SequenceFlags: SeqFirst, SequenceCount: 0x3fff, Data: []byte{0}}, "1ffe7fff000000"},
+		{"continuation", Packet{APID: 1, SequenceFlags: SeqContinuation, SequenceCount: 42, Data: []byte("x")}, "0001002a000078"},
+		{"last", Packet{APID: 1, SequenceFlags: SeqLast, SequenceCount: 43, Data: []byte("y")}, "0001802b000079"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Encode(tt.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(data); got != tt.hex {
+				t.Errorf("Encode = %s, want %s", got, tt.hex)
+			}
+			back, err := Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(back, tt.p) {
+				t.Errorf("Decode = %+v, want %+v", back, tt.p)
+			}
+		})
+	}
+}
+
+func TestFlagsFor(t *testing.T) {
+	tests := []struct {
+		total int
+		want  []SequenceFlags
+	}{
+		{1, []SequenceFlags{SeqUnsegmented}},
+		{2, []SequenceFlags{SeqFirst, SeqLast}},
+		{5, []SequenceFlags{SeqFirst, SeqContinuation, SeqContinuation, SeqContinuation, SeqLast}},
+	}
+	for _, tt := range tests {
+		for i, want := range tt.want {
+			if got := FlagsFor(i, tt.total); got != want {
+				t.Errorf("FlagsFor(%d, %d) = %s, want %s", i, tt.total, got, want)
+			}
+		}
+	}
+}
+
+func TestSequenceCountWrap(t *testing.T) {
+	const apid = 7
+	counters[apid].Store(sequenceCountMask)
+	if got := NextSequenceCount(apid); got != 16383 {
+		t.Fatalf("count = %d, want 16383", got)
+	}
+	if got := NextSequenceCount(apid); got != 0 {
+		t.Fatalf("count after wrap = %d, want 0", got)
+	}
+
+	// Блок сообщения, начатый перед переполнением, продолжается с нуля
+	counters[apid].Store(sequenceCountMask - 1)
+	opts := Options{SegmentAPID: apid}
+	var got []uint16
+	for i := 0; i < 3; i++ {
+		seg := model.Segment{MessageID: "wrap", SegmentIndex: i, TotalSegments: 3, Payload: "p"}
+		data, err := FrameSegment(opts, seg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p.SequenceCount)
+	}
+	if want := []uint16{16382, 16383, 0}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("block counts = %v, want %v", got, want)
+	}
+}
+
+// Сегменты разных сообщений перемежаются, но каждое сообщение получает
+// непрерывный блок счётчиков, а повтор сегмента — тот же счётчик
+func TestSegmentBlocks(t *testing.T) {
+	opts := Options{SegmentAPID: 8}
+	count := func(id string, i int) uint16 {
+		data, err := FrameSegment(opts, model.Segment{MessageID: id, SegmentIndex: i, TotalSegments: 3, Payload: "p"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := DeframeSegment(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.SegmentIndex != i {
+			t.Fatalf("deframed segment %d, want %d", p.SegmentIndex, i)
+		}
+		pkt, _ := Decode(data)
+		return pkt.SequenceCount
+	}
+
+	a0, b0 := count("a", 0), count("b", 0)
+	a2, b1, a1 := count("a", 2), count("b", 1), count("a", 1)
+	if a1 != a0+1 || a2 != a0+2 || b1 != b0+1 {
+		t.Fatalf("counts a=%d,%d,%d b=%d,%d are not contiguous per message", a0, a1, a2, b0, b1)
+	}
+	if again := count("a", 1); again != a1 {
+		t.Fatalf("resent segment got count %d, want %d", again, a1)
+	}
+}
+
+func TestPacketType(t *testing.T) {
+	tests := []struct {
+		node, destination string
+		want              uint8
+	}{
+		{"earth", "mars", TypeTelecommand},
+		{"mars", "earth", TypeTelemetry},
+		{"mro", "earth", TypeTelemetry},
+		{"earth", "", TypeTelecommand},
+		{"mars", "", TypeTelemetry},
+	}
+	for _, tt := range tests {
+		opts := Options{SegmentAPID: 9, AckAPID: 10, Node: tt.node, GroundNodes: []string{"earth"}}
+		data, err := FrameSegment(opts, model.Segment{MessageID: "t", TotalSegments: 1, Destination: tt.destination})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p, _ := Decode(data); p.Type != tt.want {
+			t.Errorf("segment %s -> %q: type %d, want %d", tt.node, tt.destination, p.Type, tt.want)
+		}
+		data, err = FrameAck(opts, model.Ack{MessageID: "t", Destination: tt.destination})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p, _ := Decode(data); p.Type != tt.want {
+			t.Errorf("ack %s -> %q: type %d, want %d", tt.node, tt.destination, p.Type, tt.want)
+		}
+	}
+}
+
+func TestRejects(t *testing.T) {
+	valid, err := Encode(Packet{APID: 1, SequenceFlags: SeqUnsegmented, Data: []byte("abc")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decodeTests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"header only", valid[:HeaderSize], "too short"},
+		{"truncated", valid[:len(valid)-1], "header declares 3"},
+		{"trailing", append(append([]byte(nil), valid...), 0), "header declares 3"},
+		{"version", append([]byte{0x20}, valid[1:]...), "unsupported packet version 1"},
+	}
+	for _, tt := range decodeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.data); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Decode error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	encodeTests := []struct {
+		name string
+		p    Packet
+	}{
+		{"empty data", Packet{APID: 1}},
+		{"oversized", Packet{APID: 1, Data: bytes.Repeat([]byte{1}, MaxDataLength+1)}},
+		{"idle APID", Packet{APID: MaxAPID + 1, Data: []byte{1}}},
+	}
+	for _, tt := range encodeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Encode(tt.p); err == nil {
+				t.Fatal("Encode accepted invalid packet")
+			}
+		})
+	}
+
+	// Флаги сегментации должны совпадать с положением сегмента в сообщении
+	data, err := FrameSegment(Options{SegmentAPID: 11}, model.Segment{MessageID: "f", SegmentIndex: 1, TotalSegments: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[2] |= 0xc0
+	if _, err := DeframeSegment(data); err == nil || !strings.Contains(err.Error(), "expected continuation") {
+		t.Fatalf("DeframeSegment error = %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package ccsds
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"transport/internal/model"
+	"transport/internal/wire"
+)
+
+// Поле данных пакета — бинарный конверт пакета wire: флаги сегментации
+// описывают положение сегмента в сообщении, а messageId и отправитель
+// передаются внутри конверта.
+//
+// Порядок пакетов. Сегменты разных сообщений отправляются пулом воркеров
+// и повторяются по таймауту, поэтому на линии они перемежаются, а пакеты
+// одного сообщения приходят в произвольном порядке. Чтобы получатель мог
+// собрать цепочку first, continuation…, last, каждое сообщение получает
+// непрерывный блок счётчиков APID: пакет сегмента i несёт счётчик base+i,
+// и повтор сегмента уходит с тем же счётчиком. Собирать сообщение нужно
+// по счётчику внутри блока, а не по порядку прихода.
+
+// Options — APID, под которыми транспорт отправляет сегменты и ACK, и
+// наземные узлы, по которым определяется тип пакета.
+type Options struct {
+	SegmentAPID uint16
+	AckAPID     uint16
+	// Node — этот узел; GroundNodes — наземные узлы. Пакет к наземному узлу —
+	// телеметрия, от него к космическому — телекоманда
+	Node        string
+	GroundNodes []string
+}
+
+// packetType возвращает тип пакета, идущего к узлу destination. Без
+// маршрутизации destination пуст, и направление задаёт сам этот узел.
+func (o Options) packetType(destination string) uint8 {
+	if destination == "" {
+		if slices.Contains(o.GroundNodes, o.Node) {
+			return TypeTelecommand
+		}
+		return TypeTelemetry
+	}
+	if slices.Contains(o.GroundNodes, destination) {
+		return TypeTelemetry
+	}
+	return TypeTelecommand
+}
+
+// FrameSegment кадрирует сегмент из SplitMessageToSegments в space packet;
+// флаги сегментации выводятся из SegmentIndex и TotalSegments, а счётчик —
+// из блока сообщения, поэтому сегменты одного сообщения образуют
+// непрерывную цепочку first, continuation…, last.
+func FrameSegment(opts Options, seg model.Segment) ([]byte, error) {
+	data, err := wire.EncodeSegment(wire.FormatBinary, seg)
+	if err != nil {
+		return nil, err
+	}
+	return Encode(Packet{
+		Type:          opts.packetType(seg.Destination),
+		APID:          opts.SegmentAPID,
+		SequenceFlags: FlagsFor(seg.SegmentIndex, seg.TotalSegments),
+		SequenceCount: segmentCounts.count(opts.SegmentAPID, seg),
+		Data:          data,
+	})
+}
+
+// FrameAck кадрирует ACK в несегментированный space packet.
+func FrameAck(opts Options, ack model.Ack) ([]byte, error) {
+	data, err := wire.EncodeAck(wire.FormatBinary, ack)
+	if err != nil {
+		return nil, err
+	}
+	return Encode(Packet{
+		Type:          opts.packetType(ack.Destination),
+		APID:          opts.AckAPID,
+		SequenceFlags: SeqUnsegmented,
+		SequenceCount: NextSequenceCount(opts.AckAPID),
+		Data:          data,
+	})
+}
+
+// DeframeSegment извлекает сегмент из space packet и сверяет флаги
+// сегментации с его положением в сообщении.
+func DeframeSegment(data []byte) (model.Segment, error) {
+	p, err := Decode(data)
+	if err != nil {
+		return model.Segment{}, err
+	}
+	seg, err := wire.DecodeSegment(p.Data)
+	if err != nil {
+		return seg, fmt.Errorf("ccsds: APID %d: %w", p.APID, err)
+	}
+	if want := FlagsFor(seg.SegmentIndex, seg.TotalSegments); p.SequenceFlags != want {
+		return seg, fmt.Errorf("ccsds: segment %d/%d carries %s flags, expected %s",
+			seg.SegmentIndex, seg.TotalSegments, p.SequenceFlags, want)
+	}
+	return seg, nil
+}
+
+// DeframeAck извлекает ACK из space packet.
+func DeframeAck(data []byte) (model.Ack, error) {
+	p, err := Decode(data)
+	if err != nil {
+		return model.Ack{}, err
+	}
+	if p.SequenceFlags != SeqUnsegmented {
+		return model.Ack{}, fmt.Errorf("ccsds: ACK packet must be unsegmented, got %s", p.SequenceFlags)
+	}
+	ack, err := wire.DecodeAck(p.Data)
+	if err != nil {
+		return ack, fmt.Errorf("ccsds: APID %d: %w", p.APID, err)
+	}
+	return ack, nil
+}
+
+// maxBlocks — сколько блоков счётчиков помнится для повторов сегментов.
+const maxBlocks = 4096
+
+// blocks выдаёт сообщениям непрерывные блоки счётчиков последовательности.
+type blocks struct {
+	mu    sync.Mutex
+	base  map[blockKey]uint16
+	order []blockKey
+}
+
+type blockKey struct {
+	apid      uint16
+	messageID string
+}
+
+var segmentCounts = &blocks{base: make(map[blockKey]uint16)}
+
+// count возвращает счётчик пакета сегмента: base+SegmentIndex по модулю 2^14.
+// Блок сообщения резервируется при первом сегменте; самые старые блоки
+// забываются, и повтор сегмента такого сообщения получит новый блок.
+func (b *blocks) count(apid uint16, seg model.Segment) uint16 {
+	key := blockKey{apid: apid, messageID: seg.MessageID}
+
+	b.mu.Lock()
+	base, ok := b.base[key]
+	if !ok {
+		base = reserveSequenceCounts(apid, max(seg.TotalSegments, 1))
+		if len(b.order) >= maxBlocks {
+			delete(b.base, b.order[0])
+			b.order = b.order[1:]
+		}
+		b.base[key] = base
+		b.order = append(b.order, key)
+	}
+	b.mu.Unlock()
+
+	return (base + uint16(seg.SegmentIndex)) & sequenceCountMask
+}
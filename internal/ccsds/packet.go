@@ -0,0 +1,138 @@
+// Package ccsds реализует кадрирование в CCSDS Space Packets
+// (CCSDS 133.0-B-2): шестибайтный первичный заголовок с APID, флагами
+// сегментации и счётчиком последовательности, за которым идёт поле данных.
+package ccsds
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ContentType — тип тела HTTP-запроса с одним space packet.
+const ContentType = "application/vnd.ccsds.space-packet"
+
+const (
+	HeaderSize = 6
+	// MaxAPID — наибольший APID пользовательских пакетов; 0x7FF зарезервирован за idle-пакетами
+	MaxAPID = 0x7fe
+	// MaxDataLength — предел поля данных: длина кодируется как (N-1) в 16 битах
+	MaxDataLength = 1 << 16
+
+	sequenceCountMask = 0x3fff
+)
+
+// Тип пакета
+const (
+	TypeTelemetry   uint8 = 0
+	TypeTelecommand uint8 = 1
+)
+
+// SequenceFlags — флаги сегментации пакета.
+type SequenceFlags uint8
+
+const (
+	SeqContinuation SequenceFlags = 0b00
+	SeqFirst        SequenceFlags = 0b01
+	SeqLast         SequenceFlags = 0b10
+	SeqUnsegmented  SequenceFlags = 0b11
+)
+
+func (f SequenceFlags) String() string {
+	switch f {
+	case SeqContinuation:
+		return "continuation"
+	case SeqFirst:
+		return "first"
+	case SeqLast:
+		return "last"
+	}
+	return "unsegmented"
+}
+
+// FlagsFor возвращает флаги сегментации для сегмента index из total.
+func FlagsFor(index, total int) SequenceFlags {
+	switch {
+	case total <= 1:
+		return SeqUnsegmented
+	case index == 0:
+		return SeqFirst
+	case index == total-1:
+		return SeqLast
+	}
+	return SeqContinuation
+}
+
+// Packet — space packet без вторичного заголовка.
+type Packet struct {
+	Type            uint8
+	SecondaryHeader bool
+	APID            uint16
+	SequenceFlags   SequenceFlags
+	SequenceCount   uint16
+	Data            []byte
+}
+
+// counters — счётчики последовательности, свои для каждого APID
+var counters [MaxAPID + 1]atomic.Uint32
+
+// NextSequenceCount возвращает очередное значение 14-битного счётчика APID.
+func NextSequenceCount(apid uint16) uint16 {
+	return reserveSequenceCounts(apid, 1)
+}
+
+// reserveSequenceCounts занимает n подряд идущих значений счётчика APID и
+// возвращает первое.
+func reserveSequenceCounts(apid uint16, n int) uint16 {
+	return uint16((counters[apid].Add(uint32(n)) - uint32(n)) & sequenceCountMask)
+}
+
+func Encode(p Packet) ([]byte, error) {
+	if p.APID > MaxAPID {
+		return nil, fmt.Errorf("ccsds: APID %d out of range", p.APID)
+	}
+	if len(p.Data) == 0 || len(p.Data) > MaxDataLength {
+		return nil, fmt.Errorf("ccsds: data field length %d out of range 1..%d", len(p.Data), MaxDataLength)
+	}
+
+	// Версия пакета — 000
+	id := uint16(p.Type&1)<<12 | p.APID
+	if p.SecondaryHeader {
+		id |= 1 << 11
+	}
+	seq := uint16(p.SequenceFlags&0b11)<<14 | p.SequenceCount&sequenceCountMask
+
+	out := make([]byte, HeaderSize, HeaderSize+len(p.Data))
+	binary.BigEndian.PutUint16(out[0:], id)
+	binary.BigEndian.PutUint16(out[2:], seq)
+	binary.BigEndian.PutUint16(out[4:], uint16(len(p.Data)-1))
+	return append(out, p.Data...), nil
+}
+
+// Decode разбирает ровно один пакет: длина data должна совпадать с
+// длиной, объявленной в заголовке.
+func Decode(data []byte) (Packet, error) {
+	if len(data) < HeaderSize+1 {
+		return Packet{}, errors.New("ccsds: packet too short")
+	}
+
+	id := binary.BigEndian.Uint16(data[0:])
+	if version := id >> 13; version != 0 {
+		return Packet{}, fmt.Errorf("ccsds: unsupported packet version %d", version)
+	}
+	seq := binary.BigEndian.Uint16(data[2:])
+	length := int(binary.BigEndian.Uint16(data[4:])) + 1
+	if len(data)-HeaderSize != length {
+		return Packet{}, fmt.Errorf("ccsds: data field is %d bytes, header declares %d", len(data)-HeaderSize, length)
+	}
+
+	return Packet{
+		Type:            uint8(id>>12) & 1,
+		SecondaryHeader: id&(1<<11) != 0,
+		APID:            id & 0x7ff,
+		SequenceFlags:   SequenceFlags(seq >> 14),
+		SequenceCount:   seq & sequenceCountMask,
+		Data:            data[HeaderSize:],
+	}, nil
+}
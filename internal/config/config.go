@@ -1,10 +1,17 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"transport/internal/bundle"
+	"transport/internal/ccsds"
+	"transport/internal/wire"
 )
 
 type Config struct {
@@ -17,6 +24,120 @@ type Config struct {
 	AckTimeout    time.Duration
 	CheckInterval time.Duration
 	TransportPort string
+
+	KafkaSegmentTopic          string
+	KafkaGroupID               string
+	KafkaClientID              string
+	KafkaTLSEnabled            bool
+	KafkaTLSCAFile             string
+	KafkaTLSCertFile           string
+	KafkaTLSKeyFile            string
+	KafkaTLSInsecureSkipVerify bool
+	KafkaSASLMechanism         string
+	KafkaSASLUsername          string
+	KafkaSASLPassword          string
+	KafkaCompression           string
+	KafkaBatchSize             int
+	KafkaBatchTimeout          time.Duration
+	KafkaRequiredAcks          string
+
+	OutboxPath           string
+	OutboxRetryMin       time.Duration
+	OutboxRetryMax       time.Duration
+	OutboxRequestTimeout time.Duration
+
+	WebhooksPath  string
+	WebhookSecret string
+	// WebhookHosts — хосты (или host:port), на которые разрешено отправлять
+	// события webhook и callbackUrl; пустой список запрещает их все
+	WebhookHosts []string
+	AdminToken   string
+	// EventsOrigins — origin-ы веб-интерфейсов, которым разрешено читать
+	// поток событий /events из браузера
+	EventsOrigins []string
+	// WebhookOutboxPath — отдельный outbox событий webhook; событие
+	// отбрасывается после WebhookMaxAttempts попыток или через WebhookTTL
+	WebhookOutboxPath  string
+	WebhookMaxAttempts int
+	WebhookTTL         time.Duration
+
+	ReadinessTimeout     time.Duration
+	MaxConsumerLag       int64
+	ConsumerStallTimeout time.Duration
+
+	ConsumerBackoffMin time.Duration
+	ConsumerBackoffMax time.Duration
+
+	ForwardWorkers   int
+	ForwardQueueSize int
+	ForwardOrdered   bool
+
+	RetryTopic         string
+	DLQTopic           string
+	ForwardMaxAttempts int
+	RetryDelay         time.Duration
+	RetryDelayMax      time.Duration
+
+	StateDir        string
+	ShutdownTimeout time.Duration
+
+	QueueBackend string
+	QueueDir     string
+
+	WireFormat wire.Format
+
+	// ChannelFraming — упаковка сегментов и ACK для канала: wire, bundle или ccsds
+	ChannelFraming string
+	Bundle         bundle.Options
+	SpacePacket    ccsds.Options
+
+	// GreenWait — сколько ждать green-сегменты после сборки red-части
+	GreenWait time.Duration
+
+	// ReassemblyMaxSegments — предел сегментов в буфере сборки; при
+	// превышении вытесняются наименее срочные сообщения. 0 — без предела
+	ReassemblyMaxSegments int
+
+	// ContactPlan — файл или URL плана сеансов связи; пусто — линия открыта всегда
+	ContactPlan        string
+	ContactPlanRefresh time.Duration
+
+	// NodeID — идентификатор узла в графе контактов. RoutingGraph — файл
+	// графа; пусто — без маршрутизации через ретрансляторы.
+	// RouteDestination — узел назначения исходящих сообщений
+	NodeID           string
+	RoutingGraph     string
+	RouteDestination string
+
+	// AppEndpoints — адреса приложений по узлам: сообщение доставляется
+	// приложению узла назначения, итоговый статус — приложению узла-отправителя
+	AppEndpoints map[string]string
+
+	// AddressGroups — групповые адреса: сообщение, адресованное группе,
+	// рассылается каждому её узлу
+	AddressGroups map[string][]string
+
+	// CustodyTransfer — запрашивать передачу ответственности за сегменты и
+	// принимать её. CustodyTimeout — ожидание итогового статуса сообщения,
+	// все сегменты которого переданы на хранение следующему узлу
+	CustodyTransfer bool
+	CustodyTimeout  time.Duration
+
+	// EncryptionKeyring — файл ключей шифрования содержимого сообщений;
+	// пусто — сообщения не шифруются. KeyringRefresh — период перечитывания
+	// файла для ротации ключей
+	EncryptionKeyring string
+	KeyringRefresh    time.Duration
+
+	// AuthKeys — ключи подписи кадров по узлам, включая ключ этого узла;
+	// пусто — подписи не ставятся и не проверяются. ReplayWindow —
+	// допустимый возраст подписи, в течение которого повтор кадра отклоняется
+	AuthKeys     map[string]string
+	ReplayWindow time.Duration
+
+	// MetricsSenders — отправители, которые различаются в метке sender
+	// метрик; остальные учитываются под меткой "other"
+	MetricsSenders []string
 }
 
 func Load() *Config {
@@ -24,6 +145,9 @@ func Load() *Config {
 	if err != nil {
 		log.Fatalf("[Config] Invalid SEGMENT_SIZE: %v", err)
 	}
+	if segmentSize < 1 {
+		log.Fatalf("[Config] Invalid SEGMENT_SIZE: %d (must be at least 1)", segmentSize)
+	}
 
 	timeout, err := time.ParseDuration(getEnv("TIMEOUT_DURATION", "10s"))
 	if err != nil {
@@ -40,6 +164,237 @@ func Load() *Config {
 		log.Fatalf("[Config] Invalid CHECK_INTERVAL: %v", err)
 	}
 
+	greenWait, err := time.ParseDuration(getEnv("GREEN_WAIT", "2s"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid GREEN_WAIT: %v", err)
+	}
+
+	reassemblyMaxSegments, err := strconv.Atoi(getEnv("REASSEMBLY_MAX_SEGMENTS", "10000"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid REASSEMBLY_MAX_SEGMENTS: %v", err)
+	}
+
+	contactPlanRefresh, err := time.ParseDuration(getEnv("CONTACT_PLAN_REFRESH", "5m"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid CONTACT_PLAN_REFRESH: %v", err)
+	}
+
+	appEndpoints, err := parseEndpoints(os.Getenv("APP_ENDPOINTS"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid APP_ENDPOINTS: %v", err)
+	}
+
+	addressGroups, err := parseGroups(os.Getenv("ADDRESS_GROUPS"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid ADDRESS_GROUPS: %v", err)
+	}
+
+	custodyTransfer, err := strconv.ParseBool(getEnv("CUSTODY_TRANSFER", "false"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid CUSTODY_TRANSFER: %v", err)
+	}
+
+	custodyTimeout, err := time.ParseDuration(getEnv("CUSTODY_TIMEOUT", "24h"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid CUSTODY_TIMEOUT: %v", err)
+	}
+
+	authKeys, err := parseKeys(os.Getenv("AUTH_KEYS"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid AUTH_KEYS: %v", err)
+	}
+
+	replayWindow, err := time.ParseDuration(getEnv("REPLAY_WINDOW", "1h"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid REPLAY_WINDOW: %v", err)
+	}
+
+	keyringRefresh, err := time.ParseDuration(getEnv("KEYRING_REFRESH", "1m"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid KEYRING_REFRESH: %v", err)
+	}
+
+	kafkaTLSEnabled, err := strconv.ParseBool(getEnv("KAFKA_TLS_ENABLED", "false"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid KAFKA_TLS_ENABLED: %v", err)
+	}
+
+	kafkaTLSInsecure, err := strconv.ParseBool(getEnv("KAFKA_TLS_INSECURE_SKIP_VERIFY", "false"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid KAFKA_TLS_INSECURE_SKIP_VERIFY: %v", err)
+	}
+
+	kafkaBatchSize, err := strconv.Atoi(getEnv("KAFKA_BATCH_SIZE", "100"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid KAFKA_BATCH_SIZE: %v", err)
+	}
+	if kafkaBatchSize < 1 {
+		log.Fatalf("[Config] Invalid KAFKA_BATCH_SIZE: %d (must be at least 1)", kafkaBatchSize)
+	}
+
+	kafkaBatchTimeout, err := time.ParseDuration(getEnv("KAFKA_BATCH_TIMEOUT", "1s"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid KAFKA_BATCH_TIMEOUT: %v", err)
+	}
+
+	outboxRetryMin, err := time.ParseDuration(getEnv("OUTBOX_RETRY_MIN", "1s"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid OUTBOX_RETRY_MIN: %v", err)
+	}
+
+	outboxRetryMax, err := time.ParseDuration(getEnv("OUTBOX_RETRY_MAX", "1m"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid OUTBOX_RETRY_MAX: %v", err)
+	}
+
+	outboxRequestTimeout, err := time.ParseDuration(getEnv("OUTBOX_REQUEST_TIMEOUT", "5s"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid OUTBOX_REQUEST_TIMEOUT: %v", err)
+	}
+
+	webhookMaxAttempts, err := strconv.Atoi(getEnv("WEBHOOK_MAX_ATTEMPTS", "10"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid WEBHOOK_MAX_ATTEMPTS: %v", err)
+	}
+	if webhookMaxAttempts < 1 {
+		log.Fatalf("[Config] Invalid WEBHOOK_MAX_ATTEMPTS: %d (must be at least 1)", webhookMaxAttempts)
+	}
+
+	webhookTTL, err := time.ParseDuration(getEnv("WEBHOOK_TTL", "1h"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid WEBHOOK_TTL: %v", err)
+	}
+	if webhookTTL <= 0 {
+		log.Fatalf("[Config] Invalid WEBHOOK_TTL: %v (must be greater than 0)", webhookTTL)
+	}
+
+	readinessTimeout, err := time.ParseDuration(getEnv("READINESS_TIMEOUT", "2s"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid READINESS_TIMEOUT: %v", err)
+	}
+
+	maxConsumerLag, err := strconv.ParseInt(getEnv("MAX_CONSUMER_LAG", "0"), 10, 64)
+	if err != nil {
+		log.Fatalf("[Config] Invalid MAX_CONSUMER_LAG: %v", err)
+	}
+
+	consumerStallTimeout, err := time.ParseDuration(getEnv("CONSUMER_STALL_TIMEOUT", "1m"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid CONSUMER_STALL_TIMEOUT: %v", err)
+	}
+
+	consumerBackoffMin, err := time.ParseDuration(getEnv("CONSUMER_BACKOFF_MIN", "500ms"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid CONSUMER_BACKOFF_MIN: %v", err)
+	}
+
+	consumerBackoffMax, err := time.ParseDuration(getEnv("CONSUMER_BACKOFF_MAX", "30s"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid CONSUMER_BACKOFF_MAX: %v", err)
+	}
+
+	forwardWorkers, err := strconv.Atoi(getEnv("FORWARD_WORKERS", "8"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid FORWARD_WORKERS: %v", err)
+	}
+	if forwardWorkers < 1 {
+		log.Fatalf("[Config] Invalid FORWARD_WORKERS: %d (must be at least 1)", forwardWorkers)
+	}
+
+	forwardQueueSize, err := strconv.Atoi(getEnv("FORWARD_QUEUE_SIZE", "256"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid FORWARD_QUEUE_SIZE: %v", err)
+	}
+	if forwardQueueSize < 1 {
+		log.Fatalf("[Config] Invalid FORWARD_QUEUE_SIZE: %d (must be at least 1)", forwardQueueSize)
+	}
+
+	forwardOrdered, err := strconv.ParseBool(getEnv("FORWARD_ORDERED", "true"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid FORWARD_ORDERED: %v", err)
+	}
+
+	forwardMaxAttempts, err := strconv.Atoi(getEnv("FORWARD_MAX_ATTEMPTS", "5"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid FORWARD_MAX_ATTEMPTS: %v", err)
+	}
+	if forwardMaxAttempts < 1 {
+		log.Fatalf("[Config] Invalid FORWARD_MAX_ATTEMPTS: %d (must be at least 1)", forwardMaxAttempts)
+	}
+
+	retryDelay, err := time.ParseDuration(getEnv("RETRY_DELAY", "10s"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid RETRY_DELAY: %v", err)
+	}
+
+	retryDelayMax, err := time.ParseDuration(getEnv("RETRY_DELAY_MAX", "10m"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid RETRY_DELAY_MAX: %v", err)
+	}
+
+	shutdownTimeout, err := time.ParseDuration(getEnv("SHUTDOWN_TIMEOUT", "30s"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid SHUTDOWN_TIMEOUT: %v", err)
+	}
+
+	queueBackend := strings.ToLower(getEnv("QUEUE_BACKEND", "kafka"))
+	switch queueBackend {
+	case "kafka", "memory", "file":
+	default:
+		log.Fatalf("[Config] Invalid QUEUE_BACKEND: %q (expected kafka, memory or file)", queueBackend)
+	}
+
+	wireFormat, err := wire.ParseFormat(strings.ToLower(getEnv("WIRE_FORMAT", "json")))
+	if err != nil {
+		log.Fatalf("[Config] Invalid WIRE_FORMAT: %v", err)
+	}
+
+	channelFraming := strings.ToLower(getEnv("CHANNEL_FRAMING", "wire"))
+	switch channelFraming {
+	case "wire", "bundle", "ccsds":
+	default:
+		log.Fatalf("[Config] Invalid CHANNEL_FRAMING: %q (expected wire, bundle or ccsds)", channelFraming)
+	}
+
+	bundleSource, err := bundle.ParseEID(getEnv("BUNDLE_SOURCE_EID", "dtn://earth/transport"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid BUNDLE_SOURCE_EID: %v", err)
+	}
+
+	bundleDestination, err := bundle.ParseEID(getEnv("BUNDLE_DESTINATION_EID", "dtn://mars/transport"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid BUNDLE_DESTINATION_EID: %v", err)
+	}
+
+	bundleNodeEIDs, err := parseEIDs(os.Getenv("BUNDLE_NODE_EIDS"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid BUNDLE_NODE_EIDS: %v", err)
+	}
+
+	bundleLifetime, err := time.ParseDuration(getEnv("BUNDLE_LIFETIME", "24h"))
+	if err != nil {
+		log.Fatalf("[Config] Invalid BUNDLE_LIFETIME: %v", err)
+	}
+
+	bundleCRC, err := bundle.ParseCRCType(strings.ToLower(getEnv("BUNDLE_CRC", "crc32c")))
+	if err != nil {
+		log.Fatalf("[Config] Invalid BUNDLE_CRC: %v", err)
+	}
+
+	segmentAPID, err := parseAPID("CCSDS_SEGMENT_APID", "100")
+	if err != nil {
+		log.Fatalf("[Config] Invalid CCSDS_SEGMENT_APID: %v", err)
+	}
+
+	ackAPID, err := parseAPID("CCSDS_ACK_APID", "101")
+	if err != nil {
+		log.Fatalf("[Config] Invalid CCSDS_ACK_APID: %v", err)
+	}
+
+	nodeID := getEnv("NODE_ID", "earth")
+	metricsSenders := parseList(os.Getenv("METRICS_SENDERS"))
+	groundNodes := parseList(getEnv("CCSDS_GROUND_NODES", "earth"))
+
 	cfg := &Config{
 		AppMarsURL:    os.Getenv("APP_MARS_URL"),
 		AppEarthURL:   os.Getenv("APP_EARTH_URL"),
@@ -50,22 +405,377 @@ func Load() *Config {
 		AckTimeout:    ackTimeout,
 		CheckInterval: interval,
 		TransportPort: getEnv("TRANSPORT_PORT", "4000"),
+
+		KafkaSegmentTopic:          getEnv("KAFKA_SEGMENT_TOPIC", "segment-topic"),
+		KafkaGroupID:               getEnv("KAFKA_GROUP_ID", "transport-group"),
+		KafkaClientID:              getEnv("KAFKA_CLIENT_ID", "marslink-transport"),
+		KafkaTLSEnabled:            kafkaTLSEnabled,
+		KafkaTLSCAFile:             os.Getenv("KAFKA_TLS_CA_FILE"),
+		KafkaTLSCertFile:           os.Getenv("KAFKA_TLS_CERT_FILE"),
+		KafkaTLSKeyFile:            os.Getenv("KAFKA_TLS_KEY_FILE"),
+		KafkaTLSInsecureSkipVerify: kafkaTLSInsecure,
+		KafkaSASLMechanism:         os.Getenv("KAFKA_SASL_MECHANISM"),
+		KafkaSASLUsername:          os.Getenv("KAFKA_SASL_USERNAME"),
+		KafkaSASLPassword:          os.Getenv("KAFKA_SASL_PASSWORD"),
+		KafkaCompression:           getEnv("KAFKA_COMPRESSION", "none"),
+		KafkaBatchSize:             kafkaBatchSize,
+		KafkaBatchTimeout:          kafkaBatchTimeout,
+		KafkaRequiredAcks:          getEnv("KAFKA_REQUIRED_ACKS", "all"),
+
+		OutboxPath:           getEnv("OUTBOX_PATH", "data/outbox.json"),
+		OutboxRetryMin:       outboxRetryMin,
+		OutboxRetryMax:       outboxRetryMax,
+		OutboxRequestTimeout: outboxRequestTimeout,
+
+		WebhooksPath:  getEnv("WEBHOOKS_PATH", "data/webhooks.json"),
+		WebhookSecret: os.Getenv("WEBHOOK_SECRET"),
+		WebhookHosts:  parseList(os.Getenv("WEBHOOK_ALLOWED_HOSTS")),
+		AdminToken:    os.Getenv("ADMIN_TOKEN"),
+		EventsOrigins: parseList(os.Getenv("EVENTS_ALLOWED_ORIGINS")),
+
+		WebhookOutboxPath:  getEnv("WEBHOOK_OUTBOX_PATH", "data/webhook_outbox.json"),
+		WebhookMaxAttempts: webhookMaxAttempts,
+		WebhookTTL:         webhookTTL,
+
+		ReadinessTimeout:     readinessTimeout,
+		MaxConsumerLag:       maxConsumerLag,
+		ConsumerStallTimeout: consumerStallTimeout,
+
+		ConsumerBackoffMin: consumerBackoffMin,
+		ConsumerBackoffMax: consumerBackoffMax,
+
+		ForwardWorkers:   forwardWorkers,
+		ForwardQueueSize: forwardQueueSize,
+		ForwardOrdered:   forwardOrdered,
+
+		RetryTopic:         os.Getenv("RETRY_TOPIC"),
+		DLQTopic:           os.Getenv("DLQ_TOPIC"),
+		ForwardMaxAttempts: forwardMaxAttempts,
+		RetryDelay:         retryDelay,
+		RetryDelayMax:      retryDelayMax,
+
+		StateDir:        getEnv("STATE_DIR", "data"),
+		ShutdownTimeout: shutdownTimeout,
+
+		QueueBackend: queueBackend,
+		QueueDir:     getEnv("QUEUE_DIR", "data/queue"),
+
+		WireFormat: wireFormat,
+
+		ChannelFraming: channelFraming,
+		Bundle: bundle.Options{
+			Source:      bundleSource,
+			Destination: bundleDestination,
+			Lifetime:    bundleLifetime,
+			CRC:         bundleCRC,
+			Node:        nodeID,
+			NodeEIDs:    bundleNodeEIDs,
+		},
+		SpacePacket: ccsds.Options{
+			SegmentAPID: segmentAPID,
+			AckAPID:     ackAPID,
+			Node:        nodeID,
+			GroundNodes: groundNodes,
+		},
+
+		GreenWait: greenWait,
+
+		ReassemblyMaxSegments: reassemblyMaxSegments,
+
+		ContactPlan:        os.Getenv("CONTACT_PLAN"),
+		ContactPlanRefresh: contactPlanRefresh,
+
+		NodeID:           nodeID,
+		RoutingGraph:     os.Getenv("ROUTING_GRAPH"),
+		RouteDestination: getEnv("ROUTE_DESTINATION", "mars"),
+
+		AppEndpoints:  appEndpoints,
+		AddressGroups: addressGroups,
+
+		CustodyTransfer: custodyTransfer,
+		CustodyTimeout:  custodyTimeout,
+
+		EncryptionKeyring: os.Getenv("ENCRYPTION_KEYRING"),
+		KeyringRefresh:    keyringRefresh,
+
+		AuthKeys:     authKeys,
+		ReplayWindow: replayWindow,
+
+		MetricsSenders: metricsSenders,
+	}
+
+	// По умолчанию служебные топики именуются от основного
+	if cfg.RetryTopic == "" {
+		cfg.RetryTopic = cfg.KafkaSegmentTopic + ".retry"
+	}
+	if cfg.DLQTopic == "" {
+		cfg.DLQTopic = cfg.KafkaSegmentTopic + ".dlq"
+	}
+
+	// APP_EARTH_URL и APP_MARS_URL — адреса приложений узлов earth и mars,
+	// если они не заданы в APP_ENDPOINTS
+	if _, ok := cfg.AppEndpoints["earth"]; !ok && cfg.AppEarthURL != "" {
+		cfg.AppEndpoints["earth"] = cfg.AppEarthURL
+	}
+	if _, ok := cfg.AppEndpoints["mars"]; !ok && cfg.AppMarsURL != "" {
+		cfg.AppEndpoints["mars"] = cfg.AppMarsURL
 	}
 
 	log.Println("[Config] Loaded configuration:")
 	log.Printf("  APP_MARS_URL:    %s", cfg.AppMarsURL)
 	log.Printf("  APP_EARTH_URL:   %s", cfg.AppEarthURL)
 	log.Printf("  CHANNEL_URL:     %s", cfg.ChannelURL)
+	log.Printf("  QUEUE_BACKEND:   %s", cfg.QueueBackend)
+	if cfg.QueueBackend == "file" {
+		log.Printf("  QUEUE_DIR:       %s", cfg.QueueDir)
+	}
 	log.Printf("  KAFKA_BROKERS:   %s", cfg.KafkaBrokers)
+	log.Printf("  KAFKA_TOPIC:     %s (group %s, client %s)", cfg.KafkaSegmentTopic, cfg.KafkaGroupID, cfg.KafkaClientID)
+	log.Printf("  KAFKA_TLS:       %v", cfg.KafkaTLSEnabled)
+	log.Printf("  KAFKA_SASL:      %s", cfg.KafkaSASLMechanism)
+	log.Printf("  KAFKA_WRITER:    compression=%s, batch=%d/%v, acks=%s", cfg.KafkaCompression, cfg.KafkaBatchSize, cfg.KafkaBatchTimeout, cfg.KafkaRequiredAcks)
 	log.Printf("  SEGMENT_SIZE:    %d", cfg.SegmentSize)
+	log.Printf("  WIRE_FORMAT:     %s", cfg.WireFormat)
+	log.Printf("  CHANNEL_FRAMING: %s", cfg.ChannelFraming)
+	switch cfg.ChannelFraming {
+	case "bundle":
+		log.Printf("  BUNDLES:         %s -> %s, lifetime %v, crc %s",
+			cfg.Bundle.Source, cfg.Bundle.Destination, cfg.Bundle.Lifetime, cfg.Bundle.CRC)
+		eidNodes := make([]string, 0, len(cfg.Bundle.NodeEIDs))
+		for node := range cfg.Bundle.NodeEIDs {
+			eidNodes = append(eidNodes, node)
+		}
+		sort.Strings(eidNodes)
+		for _, node := range eidNodes {
+			log.Printf("  EID %-13s %s", node+":", cfg.Bundle.NodeEIDs[node])
+		}
+	case "ccsds":
+		log.Printf("  CCSDS_APID:      segments %d, acks %d", cfg.SpacePacket.SegmentAPID, cfg.SpacePacket.AckAPID)
+		log.Printf("  CCSDS_GROUND:    %s", strings.Join(cfg.SpacePacket.GroundNodes, ", "))
+	}
 	log.Printf("  TIMEOUT:         %v", cfg.Timeout)
 	log.Printf("  ACK_TIMEOUT:     %v", cfg.AckTimeout)
 	log.Printf("  CHECK_INTERVAL:  %v", cfg.CheckInterval)
+	log.Printf("  GREEN_WAIT:      %v", cfg.GreenWait)
+	log.Printf("  REASSEMBLY_MAX:  %d segments", cfg.ReassemblyMaxSegments)
+	if cfg.ContactPlan != "" {
+		log.Printf("  CONTACT_PLAN:    %s (refresh %v)", cfg.ContactPlan, cfg.ContactPlanRefresh)
+	} else {
+		log.Printf("  CONTACT_PLAN:    none, link always available")
+	}
+	log.Printf("  NODE_ID:         %s", cfg.NodeID)
+	for _, node := range cfg.AppNodes() {
+		log.Printf("  APP %-13s %s", node+":", cfg.AppEndpoints[node])
+	}
+	if cfg.RoutingGraph != "" {
+		log.Printf("  ROUTING_GRAPH:   %s (destination %s)", cfg.RoutingGraph, cfg.RouteDestination)
+	}
+	groups := make([]string, 0, len(cfg.AddressGroups))
+	for group := range cfg.AddressGroups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		log.Printf("  GROUP %-11s %s", group+":", strings.Join(cfg.AddressGroups[group], ", "))
+	}
+	if cfg.CustodyTransfer {
+		log.Printf("  CUSTODY:         enabled (status timeout %v)", cfg.CustodyTimeout)
+		if !cfg.AcceptsCustody() {
+			log.Printf("[WARN] CUSTODY_TRANSFER=true, but QUEUE_BACKEND=memory is not durable: this node will NOT accept custody of relayed segments; use QUEUE_BACKEND=file or kafka")
+		}
+	}
+	if cfg.EncryptionKeyring != "" {
+		log.Printf("  ENCRYPTION:      %s (refresh %v)", cfg.EncryptionKeyring, cfg.KeyringRefresh)
+	}
+	if len(cfg.AuthKeys) > 0 {
+		log.Printf("  AUTH:            %d node key(s), replay window %v", len(cfg.AuthKeys), cfg.ReplayWindow)
+	} else {
+		log.Printf("  AUTH:            disabled, frames are not signed")
+	}
 	log.Printf("  TRANSPORT_PORT:  %s", cfg.TransportPort)
+	log.Printf("  OUTBOX_PATH:     %s", cfg.OutboxPath)
+	log.Printf("  OUTBOX_RETRY:    %v..%v", cfg.OutboxRetryMin, cfg.OutboxRetryMax)
+	log.Printf("  WEBHOOKS_PATH:   %s", cfg.WebhooksPath)
+	if len(cfg.WebhookHosts) > 0 {
+		log.Printf("  WEBHOOK_HOSTS:   %s", strings.Join(cfg.WebhookHosts, ", "))
+	} else {
+		log.Printf("  WEBHOOK_HOSTS:   none, callback URLs and webhooks are rejected")
+	}
+	log.Printf("  WEBHOOK_OUTBOX:  %s (max %d attempts, ttl %v)", cfg.WebhookOutboxPath, cfg.WebhookMaxAttempts, cfg.WebhookTTL)
+	if cfg.AdminToken == "" {
+		log.Printf("[WARN] ADMIN_TOKEN is not set: admin API and event stream are disabled")
+	}
+	if len(cfg.EventsOrigins) > 0 {
+		log.Printf("  EVENTS_ORIGINS:  %s", strings.Join(cfg.EventsOrigins, ", "))
+	}
+	log.Printf("  FORWARD_POOL:    %d workers, queue %d, ordered=%v", cfg.ForwardWorkers, cfg.ForwardQueueSize, cfg.ForwardOrdered)
+	log.Printf("  RETRY_TOPIC:     %s (max %d attempts, delay %v..%v)", cfg.RetryTopic, cfg.ForwardMaxAttempts, cfg.RetryDelay, cfg.RetryDelayMax)
+	log.Printf("  DLQ_TOPIC:       %s", cfg.DLQTopic)
+	if len(cfg.MetricsSenders) > 0 {
+		log.Printf("  METRICS_SENDERS: %s", strings.Join(cfg.MetricsSenders, ", "))
+	} else {
+		log.Printf("  METRICS_SENDERS: none, all senders reported as other")
+	}
+	log.Printf("  STATE_DIR:       %s", cfg.StateDir)
+	log.Printf("  SHUTDOWN:        %v", cfg.ShutdownTimeout)
 
 	return cfg
 }
 
+// AppURL возвращает адрес приложения узла node; пустой node — этот узел.
+func (c *Config) AppURL(node string) string {
+	if node == "" {
+		node = c.NodeID
+	}
+	return c.AppEndpoints[node]
+}
+
+// WebhookAllowed сообщает, можно ли отправлять события на адрес raw:
+// http(s)-URL без учётных данных, хост которого (или host:port) указан в
+// WEBHOOK_ALLOWED_HOSTS. Адрес задаёт клиент, поэтому без списка транспорт
+// мог бы обращаться от своего имени к внутренним сервисам.
+func (c *Config) WebhookAllowed(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return false
+	}
+	for _, host := range c.WebhookHosts {
+		if strings.EqualFold(host, u.Hostname()) || strings.EqualFold(host, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsCustody сообщает, принимает ли узел ответственность за сегменты,
+// которые он ретранслирует: только при очереди, переживающей перезапуск.
+// Как узел назначения он принимает её через журнал сборки в STATE_DIR.
+func (c *Config) AcceptsCustody() bool {
+	return c.CustodyTransfer && c.QueueBackend != "memory"
+}
+
+// AppNodes возвращает узлы с известными адресами приложений по алфавиту.
+func (c *Config) AppNodes() []string {
+	nodes := make([]string, 0, len(c.AppEndpoints))
+	for node := range c.AppEndpoints {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// parseList разбирает список узлов через запятую, пропуская пустые элементы.
+func parseList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseEndpoints разбирает список вида "earth=http://app-earth:3000,mars=http://app-mars:3010".
+func parseEndpoints(raw string) (map[string]string, error) {
+	endpoints := make(map[string]string)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		node, url, ok := strings.Cut(item, "=")
+		node, url = strings.TrimSpace(node), strings.TrimSpace(url)
+		if !ok || node == "" || url == "" {
+			return nil, fmt.Errorf("entry %q is not node=url", item)
+		}
+		endpoints[node] = strings.TrimRight(url, "/")
+	}
+	return endpoints, nil
+}
+
+// parseEIDs разбирает список вида "mars=ipn:2.1,mro=dtn://mro/transport".
+func parseEIDs(raw string) (map[string]bundle.EID, error) {
+	eids := make(map[string]bundle.EID)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		node, value, ok := strings.Cut(item, "=")
+		node = strings.TrimSpace(node)
+		if !ok || node == "" {
+			return nil, fmt.Errorf("entry %q is not node=eid", item)
+		}
+		eid, err := bundle.ParseEID(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", node, err)
+		}
+		eids[node] = eid
+	}
+	return eids, nil
+}
+
+// parseGroups разбирает список вида "habitats=hab-alpha|hab-beta,relays=mro|tgo".
+func parseGroups(raw string) (map[string][]string, error) {
+	groups := make(map[string][]string)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		group, list, ok := strings.Cut(item, "=")
+		group = strings.TrimSpace(group)
+		if !ok || group == "" {
+			return nil, fmt.Errorf("entry %q is not group=node|node", item)
+		}
+		var members []string
+		for _, node := range strings.Split(list, "|") {
+			if node = strings.TrimSpace(node); node != "" {
+				members = append(members, node)
+			}
+		}
+		if len(members) == 0 {
+			return nil, fmt.Errorf("group %q has no members", group)
+		}
+		groups[group] = members
+	}
+	return groups, nil
+}
+
+// parseKeys разбирает список вида "earth=<секрет>,mars=<секрет>".
+// Секрет короче 16 байт считается ошибкой конфигурации.
+func parseKeys(raw string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		node, key, ok := strings.Cut(item, "=")
+		node = strings.TrimSpace(node)
+		if !ok || node == "" {
+			return nil, fmt.Errorf("entry for %q is not node=key", node)
+		}
+		if len(key) < 16 {
+			return nil, fmt.Errorf("key for %q is shorter than 16 bytes", node)
+		}
+		keys[node] = key
+	}
+	return keys, nil
+}
+
+// parseAPID читает APID пакетов CCSDS: 0..2046, 2047 зарезервирован за idle-пакетами.
+func parseAPID(key, fallback string) (uint16, error) {
+	v, err := strconv.ParseUint(getEnv(key, fallback), 10, 16)
+	if err != nil {
+		return 0, err
+	}
+	if v > ccsds.MaxAPID {
+		return 0, fmt.Errorf("APID %d out of range 0..%d", v, ccsds.MaxAPID)
+	}
+	return uint16(v), nil
+}
+
 func getEnv(key, fallback string) string {
 	val := os.Getenv(key)
 	if val == "" {
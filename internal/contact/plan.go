@@ -0,0 +1,230 @@
+// Package contact описывает план сеансов связи Земля–Марс (окна DSN) и
+// планирует по нему отправку сегментов в канал: сегменты ждут ближайшего
+// окна и выдаются со скоростью этого окна.
+package contact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Window — сеанс связи: интервал [Start, End), скорость линии в бит/с
+// (0 — без ограничения) и одностороннее световое время.
+type Window struct {
+	Start     time.Time
+	End       time.Time
+	DataRate  int64
+	LightTime time.Duration
+}
+
+// WindowJSON — окно в файле плана и графе контактов; lightTime задаётся
+// строкой вида "12m30s".
+type WindowJSON struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	DataRate  int64     `json:"dataRate"`
+	LightTime string    `json:"lightTime"`
+}
+
+// Contains сообщает, открыто ли окно в момент t.
+func (w Window) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// TransmitTime — время передачи size байт на скорости окна.
+func (w Window) TransmitTime(size int) time.Duration {
+	if w.DataRate <= 0 {
+		return 0
+	}
+	return time.Duration(int64(size) * 8 * int64(time.Second) / w.DataRate)
+}
+
+// Plan — план сеансов связи. Источник — путь к JSON-файлу или http(s)-URL,
+// возвращающий тот же JSON. Без источника линия считается открытой всегда.
+type Plan struct {
+	source string
+	static bool
+	client *http.Client
+
+	mu      sync.RWMutex
+	windows []Window
+	updated chan struct{}
+}
+
+func NewPlan(source string) *Plan {
+	return &Plan{
+		source:  source,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		updated: make(chan struct{}),
+	}
+}
+
+// NewStaticPlan создаёт план из заданных окон, без источника для перечитывания.
+func NewStaticPlan(windows []Window) *Plan {
+	p := &Plan{static: true, updated: make(chan struct{})}
+	p.Set(windows)
+	return p
+}
+
+// Enabled сообщает, задан ли план; без него отправка не ограничивается.
+func (p *Plan) Enabled() bool {
+	return p.source != "" || p.static
+}
+
+// Set заменяет окна плана и будит ожидающих.
+func (p *Plan) Set(windows []Window) {
+	windows = append([]Window(nil), windows...)
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Start.Before(windows[j].Start) })
+
+	p.mu.Lock()
+	p.windows = windows
+	close(p.updated)
+	p.updated = make(chan struct{})
+	p.mu.Unlock()
+}
+
+// Reload перечитывает план из источника. При ошибке действует прежний план.
+func (p *Plan) Reload(ctx context.Context) error {
+	if p.source == "" {
+		return nil
+	}
+
+	data, err := p.read(ctx)
+	if err != nil {
+		return err
+	}
+	windows, err := parseWindows(data)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", p.source, err)
+	}
+	p.Set(windows)
+
+	log.Printf("[Contact] Loaded %d contact window(s) from %s", len(windows), p.source)
+	return nil
+}
+
+func (p *Plan) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(p.source, "http://") && !strings.HasPrefix(p.source, "https://") {
+		return os.ReadFile(p.source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: status %d", p.source, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func parseWindows(data []byte) ([]Window, error) {
+	var raw []WindowJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	windows := make([]Window, 0, len(raw))
+	for i, r := range raw {
+		w, err := r.Window()
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %w", i, err)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// Window проверяет запись и преобразует её в окно.
+func (r WindowJSON) Window() (Window, error) {
+	if !r.End.After(r.Start) {
+		return Window{}, fmt.Errorf("end %s is not after start %s",
+			r.End.Format(time.RFC3339), r.Start.Format(time.RFC3339))
+	}
+	if r.DataRate < 0 {
+		return Window{}, fmt.Errorf("negative dataRate %d", r.DataRate)
+	}
+	w := Window{Start: r.Start, End: r.End, DataRate: r.DataRate}
+	if r.LightTime != "" {
+		lt, err := time.ParseDuration(r.LightTime)
+		if err != nil {
+			return Window{}, fmt.Errorf("lightTime: %w", err)
+		}
+		w.LightTime = lt
+	}
+	return w, nil
+}
+
+// Run периодически перечитывает план, пока не будет отменён ctx.
+func (p *Plan) Run(ctx context.Context, interval time.Duration) {
+	if !p.Enabled() || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.Reload(ctx); err != nil {
+				log.Printf("[Contact] Failed to reload contact plan: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Next возвращает окно, открытое в момент t, или ближайшее следующее.
+// false — будущих окон в плане нет.
+func (p *Plan) Next(t time.Time) (Window, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, w := range p.windows {
+		if t.Before(w.End) {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
+
+// AckDelay — запас к таймауту ожидания ACK, отправленного в момент t:
+// ожидание ближайшего окна и световое время туда и обратно.
+func (p *Plan) AckDelay(t time.Time) time.Duration {
+	if !p.Enabled() {
+		return 0
+	}
+	w, ok := p.Next(t)
+	if !ok {
+		return 0
+	}
+	wait := time.Duration(0)
+	if w.Start.After(t) {
+		wait = w.Start.Sub(t)
+	}
+	return wait + 2*w.LightTime
+}
+
+// changed возвращает канал, закрываемый при следующей загрузке плана.
+func (p *Plan) changed() <-chan struct{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.updated
+}
@@ -0,0 +1,169 @@
+package contact
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Scheduler выдаёт сегментам время отправки по плану: линия занята одним
+// сегментом за раз, каждый сегмент должен уложиться в окно на его скорости.
+// Пока окна нет, сегменты остаются в очереди сегментов — это и есть
+// хранилище store-and-forward.
+type Scheduler struct {
+	plan *Plan
+
+	mu       sync.Mutex
+	linkFree time.Time
+	// sent — конец последнего сегмента, которому уже разрешена отправка.
+	// Брони после него можно пересобрать при смене плана.
+	sent time.Time
+	// epoch — канал плана, по которому сделаны текущие брони.
+	epoch <-chan struct{}
+}
+
+func NewScheduler(plan *Plan) *Scheduler {
+	return &Scheduler{plan: plan}
+}
+
+// Wait блокируется до момента, когда сегмент размером size байт можно
+// передать в канал. Возвращает ошибку только при отмене ctx.
+func (s *Scheduler) Wait(ctx context.Context, size int) error {
+	if !s.plan.Enabled() {
+		return nil
+	}
+
+	held := false
+	for {
+		changed := s.plan.changed()
+		start, end, w, ok := s.reserve(time.Now(), size, changed)
+		if !ok {
+			log.Printf("[Contact] No upcoming contact window, holding segment until the plan is updated")
+			select {
+			case <-changed:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if !start.After(time.Now()) {
+			s.commit(end)
+			return nil
+		}
+		if !held && !w.Contains(time.Now()) {
+			held = true
+			log.Printf("[Contact] Link down, holding segment until window %s–%s",
+				w.Start.Format(time.RFC3339), w.End.Format(time.RFC3339))
+		}
+
+		// План может сдвинуть или отменить окно, пока сегмент ждёт: тогда
+		// бронь снимается и место ищется заново по новому плану
+		timer := time.NewTimer(time.Until(start))
+		select {
+		case <-timer.C:
+			s.commit(end)
+			return nil
+		case <-changed:
+			timer.Stop()
+			s.release(start, end, changed)
+		case <-ctx.Done():
+			timer.Stop()
+			s.release(start, end, changed)
+			return ctx.Err()
+		}
+	}
+}
+
+// WaitOpen блокируется, пока по плану нет открытого окна, или до отмены ctx.
+// Воркеры пересылки ждут окна до выбора задания, чтобы к его открытию
+// взять самое срочное.
+func (s *Scheduler) WaitOpen(ctx context.Context) {
+	if !s.plan.Enabled() {
+		return
+	}
+
+	for {
+		changed := s.plan.changed()
+		now := time.Now()
+		w, ok := s.plan.Next(now)
+		if ok && w.Contains(now) {
+			return
+		}
+
+		var wake <-chan time.Time
+		var timer *time.Timer
+		if ok {
+			timer = time.NewTimer(w.Start.Sub(now))
+			wake = timer.C
+		}
+		select {
+		case <-wake:
+		case <-changed:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// reserve занимает линию под передачу size байт и возвращает начало и конец
+// передачи. Сегмент, который не успевает передаться до конца окна,
+// переносится в следующее; сегмент длиннее любого окна начинается с начала
+// окна. Первая бронь по новому плану сбрасывает брони, сделанные по старому.
+func (s *Scheduler) reserve(now time.Time, size int, epoch <-chan struct{}) (time.Time, time.Time, Window, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.epoch != epoch {
+		s.epoch = epoch
+		s.linkFree = s.sent
+	}
+
+	t := now
+	if s.linkFree.After(t) {
+		t = s.linkFree
+	}
+
+	for {
+		w, ok := s.plan.Next(t)
+		if !ok {
+			return time.Time{}, time.Time{}, Window{}, false
+		}
+		if t.Before(w.Start) {
+			t = w.Start
+		}
+
+		d := w.TransmitTime(size)
+		if !t.Add(d).After(w.End) || (t.Equal(w.Start) && d > w.End.Sub(w.Start)) {
+			s.linkFree = t.Add(d)
+			return t, s.linkFree, w, true
+		}
+		t = w.End
+	}
+}
+
+// commit отмечает, что линия занята отправкой сегмента до end.
+func (s *Scheduler) commit(end time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if end.After(s.sent) {
+		s.sent = end
+	}
+}
+
+// release снимает неиспользованную бронь [start, end). Освободить можно
+// только последнюю бронь текущего плана: за более ранними уже стоят другие
+// сегменты, и их место пропадает до следующей смены плана.
+func (s *Scheduler) release(start, end time.Time, epoch <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.epoch == epoch && s.linkFree.Equal(end) {
+		s.linkFree = start
+	}
+}
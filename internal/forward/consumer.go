@@ -0,0 +1,342 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"transport/internal/auth"
+	"transport/internal/config"
+	"transport/internal/contact"
+	"transport/internal/framing"
+	"transport/internal/metrics"
+	"transport/internal/model"
+	"transport/internal/queue"
+	"transport/internal/routing"
+	"transport/internal/wire"
+)
+
+// Consumer читает сегменты из топиков классов срочности и пересылает их в канал.
+type Consumer struct {
+	queue    queue.SegmentQueue
+	subs     map[string]queue.Subscription
+	topics   []string
+	http     *http.Client
+	cfg      *config.Config
+	activity atomic.Int64
+	running  atomic.Bool
+	pool     *forwardPool
+	offsets  *offsetTracker
+	contacts *contact.Scheduler
+	router   *routing.Router
+	auth     *auth.Authenticator
+}
+
+// NewConsumer подписывается на топики всех классов срочности базового топика
+// topic. Сегменты передаются в канал в сеансы связи, выдаваемые contacts,
+// а сегменты для других узлов — следующему узлу маршрута, выбранному router.
+func NewConsumer(q queue.SegmentQueue, topic string, groupID string, cfg *config.Config, contacts *contact.Scheduler, router *routing.Router, signer *auth.Authenticator) *Consumer {
+	c := &Consumer{
+		queue:    q,
+		subs:     make(map[string]queue.Subscription),
+		topics:   queue.PriorityTopics(topic),
+		http:     &http.Client{Timeout: 5 * time.Second},
+		cfg:      cfg,
+		pool:     newForwardPool(cfg.ForwardWorkers, cfg.ForwardQueueSize, cfg.ForwardOrdered),
+		offsets:  newOffsetTracker(),
+		contacts: contacts,
+		router:   router,
+		auth:     signer,
+	}
+	for _, t := range c.topics {
+		c.subs[t] = q.Subscribe(t, groupID)
+	}
+	return c
+}
+
+// NewRetryConsumer создаёт consumer retry-топика. Он использует ту же
+// логику пересылки, но выдерживает задержку из заголовка x-next-attempt.
+func NewRetryConsumer(q queue.SegmentQueue, groupID string, cfg *config.Config, contacts *contact.Scheduler, router *routing.Router, signer *auth.Authenticator) *Consumer {
+	return NewConsumer(q, cfg.RetryTopic, groupID, cfg, contacts, router, signer)
+}
+
+// Start читает сегменты из очереди и передаёт их пулу воркеров для пересылки
+// в канал. Смещение фиксируется только после того, как канал принял
+// сегмент и все предыдущие в партиции, поэтому при сбое пересылки или
+// перезапуске сегмент будет прочитан повторно. Каждый топик класса читается
+// своим циклом, чтобы заполненная очередь bulk не задерживала срочные сегменты.
+func (c *Consumer) Start(ctx context.Context) {
+	log.Printf("[Consumer] Started for topics %s...", strings.Join(c.topics, ", "))
+	c.running.Store(true)
+	defer c.running.Store(false)
+	c.activity.Store(time.Now().UnixNano())
+
+	log.Printf("[Consumer] Forward pool: %d worker(s), queue size %d per class, ordered=%v",
+		len(c.pool.queues), c.cfg.ForwardQueueSize, c.cfg.ForwardOrdered)
+	// Вне сеанса связи воркеры не берут задания: сегменты остаются в очереди.
+	// С графом контактов сеанс зависит от соседа, выбранного маршрутом, и
+	// сегмент ждёт окна своего соседа в handle
+	if !c.router.Enabled() {
+		c.pool.ready = func() { c.contacts.WaitOpen(ctx) }
+	}
+	c.pool.Run(func(job forwardJob) {
+		if c.handle(ctx, job) {
+			c.offsets.Done(job.msg, c.commit)
+		}
+	})
+	defer c.pool.Close()
+
+	var loops sync.WaitGroup
+	for _, topic := range c.topics {
+		loops.Add(1)
+		go func(sub queue.Subscription) {
+			defer loops.Done()
+			c.fetchLoop(ctx, sub)
+		}(c.subs[topic])
+	}
+	loops.Wait()
+	log.Println("[Consumer] Consumer stopped")
+}
+
+// fetchLoop читает одну подписку, пока не будет отменён ctx.
+func (c *Consumer) fetchLoop(ctx context.Context, sub queue.Subscription) {
+	fetchBackoff := newBackoff(c.cfg.ConsumerBackoffMin, c.cfg.ConsumerBackoffMax)
+
+	for {
+		m, err := sub.Fetch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			delay := fetchBackoff.Next()
+			log.Printf("[Consumer] Error fetching message: %v. Retrying in %v", err, delay)
+			if !sleepCtx(ctx, delay) {
+				return
+			}
+			continue
+		}
+		fetchBackoff.Reset()
+
+		// Формат определяется по содержимому: в топике могут соседствовать
+		// JSON- и бинарные сегменты, записанные до и после смены WIRE_FORMAT
+		segment, err := wire.DecodeSegment(m.Value)
+		if err != nil {
+			// Битое сообщение не станет валидным при повторе — фиксируем и пропускаем
+			log.Printf("[Consumer] Invalid segment at %s offset %d: %v", m.Topic, m.Offset, err)
+			metrics.SegmentsConsumed.WithLabelValues("", metrics.OutcomeInvalid).Inc()
+			metrics.SegmentsDropped.WithLabelValues("", metrics.ReasonInvalidEncoding).Inc()
+			c.offsets.Add(m)
+			c.offsets.Done(m, c.commit)
+			continue
+		}
+		metrics.SegmentsConsumed.WithLabelValues(metrics.Sender(segment.Sender), metrics.OutcomeOK).Inc()
+
+		log.Printf("[Consumer] Consumed segment %d/%d from message %s (%s)",
+			segment.SegmentIndex, segment.TotalSegments, segment.MessageID, segment.Priority.Normalize())
+
+		attempt, notBefore := retryState(m)
+		c.offsets.Add(m)
+		if !c.pool.Submit(ctx, forwardJob{msg: m, segment: segment, attempt: attempt, notBefore: notBefore}) {
+			return
+		}
+	}
+}
+
+// handle пересылает сегмент в канал, а при ошибке переносит его в
+// retry-топик или DLQ. Возвращает true, если смещение можно фиксировать.
+func (c *Consumer) handle(ctx context.Context, job forwardJob) bool {
+	if wait := time.Until(job.notBefore); wait > 0 {
+		if !sleepCtx(ctx, wait) {
+			return false
+		}
+	}
+
+	if c.dropExpired(job.segment) {
+		return true
+	}
+
+	// Размер для выбора маршрута считается в режиме по умолчанию: режим
+	// соседа известен только после выбора маршрута
+	data, _, err := c.encode(job.segment, "")
+	var url, mode, contentType string
+	var wait func(context.Context, int) error
+	if err == nil {
+		url, mode, wait, err = c.link(job.segment, len(data))
+	}
+	if err == nil {
+		data, contentType, err = c.encode(job.segment, mode)
+	}
+	if err == nil {
+		// Сегмент ждёт своего места в сеансе связи; за время ожидания
+		// срок жизни может истечь, а сосед — пропасть из графа
+		err = wait(ctx, len(data))
+		if ctx.Err() != nil {
+			return false
+		}
+	}
+	if err == nil {
+		if c.dropExpired(job.segment) {
+			return true
+		}
+		// Подпись обновляется после ожидания сеанса, чтобы не выйти из окна
+		// защиты от повторов у получателя
+		if c.auth.Enabled() {
+			data, contentType, err = c.encode(job.segment, mode)
+		}
+	}
+	if err == nil {
+		err = c.forwardToChannel(ctx, job.segment, url, data, contentType)
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	if err == nil {
+		return true
+	}
+
+	log.Printf("[Channel] Failed to forward segment %d of message %s (attempt %d): %v",
+		job.segment.SegmentIndex, job.segment.MessageID, job.attempt+1, err)
+
+	// Green-сегменты доставляются не более одного раза: не повторяем
+	if job.segment.IsGreen() {
+		log.Printf("[Channel] Green segment %d of message %s dropped", job.segment.SegmentIndex, job.segment.MessageID)
+		metrics.SegmentsDropped.WithLabelValues(metrics.Sender(job.segment.Sender), metrics.ReasonGreenNotForwarded).Inc()
+		return true
+	}
+	return c.reroute(ctx, job, err)
+}
+
+// dropExpired отбрасывает просроченный сегмент: он бесполезен получателю и
+// не должен занимать канал. Отправитель узнает об истечении срока от своего AckTracker.
+func (c *Consumer) dropExpired(segment model.Segment) bool {
+	if !segment.Expired(time.Now()) {
+		return false
+	}
+	log.Printf("[Consumer] Segment %d of message %s expired, dropping", segment.SegmentIndex, segment.MessageID)
+	metrics.SegmentsExpired.WithLabelValues(metrics.Sender(segment.Sender), metrics.StageConsumer).Inc()
+	return true
+}
+
+// encode подписывает сегмент ключом этого узла и кодирует его для канала
+// в режиме mode.
+func (c *Consumer) encode(segment model.Segment, mode string) ([]byte, string, error) {
+	segment, err := c.auth.SignSegment(segment)
+	if err != nil {
+		return nil, "", err
+	}
+	return framing.EncodeSegment(c.cfg, mode, segment)
+}
+
+// link выбирает канал для сегмента и его кадрирование: следующий узел
+// маршрута, если сегмент адресован другому узлу, иначе ChannelURL. Нет
+// маршрута — ошибка пересылки: сегмент уйдёт в retry-топик и дождётся
+// обновления графа.
+func (c *Consumer) link(segment model.Segment, size int) (string, string, func(context.Context, int) error, error) {
+	if c.router.IsLocal(segment.Destination) {
+		return c.cfg.ChannelURL, "", c.contacts.Wait, nil
+	}
+	hop, err := c.router.NextHop(segment.Destination, size)
+	if err != nil {
+		return "", "", nil, err
+	}
+	log.Printf("[Routing] Segment %d of message %s to %s via %s, arrival %s",
+		segment.SegmentIndex, segment.MessageID, segment.Destination, hop.Node.ID, hop.Route.Arrival.Format(time.RFC3339))
+	return hop.Node.URL, hop.Node.Framing, hop.Wait, nil
+}
+
+func (c *Consumer) commit(m queue.Message) {
+	// Фиксация не должна прерываться остановкой: сегмент уже передан в канал
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := c.subs[m.Topic].Commit(ctx, m); err != nil {
+		log.Printf("[Consumer] Failed to commit %s offset %d (partition %d): %v", m.Topic, m.Offset, m.Partition, err)
+		return
+	}
+	c.activity.Store(time.Now().UnixNano())
+}
+
+func (c *Consumer) forwardToChannel(ctx context.Context, segment model.Segment, channelURL string, data []byte, contentType string) error {
+	url := channelURL + "/processSegment"
+
+	log.Printf("[Channel] Forwarding segment %d/%d of message %s to %s",
+		segment.SegmentIndex, segment.TotalSegments, segment.MessageID, url)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		metrics.SegmentsForwarded.WithLabelValues(metrics.Sender(segment.Sender), metrics.OutcomeError).Inc()
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		metrics.SegmentsForwarded.WithLabelValues(metrics.Sender(segment.Sender), metrics.OutcomeRejected).Inc()
+		return fmt.Errorf("bad response: status=%d, body=%s", resp.StatusCode, string(body))
+	}
+
+	log.Printf("[Channel] Segment %d of message %s successfully forwarded", segment.SegmentIndex, segment.MessageID)
+	metrics.SegmentsForwarded.WithLabelValues(metrics.Sender(segment.Sender), metrics.OutcomeOK).Inc()
+	return nil
+}
+
+// QueueDepth возвращает число сегментов, ожидающих пересылки в очередях воркеров.
+func (c *Consumer) QueueDepth() int64 {
+	return c.pool.Depth()
+}
+
+// Ping проверяет доступность очереди, из которой читает consumer.
+func (c *Consumer) Ping(ctx context.Context) error {
+	return c.queue.Ping(ctx)
+}
+
+// Lag возвращает суммарное отставание группы consumer-а по всем классам.
+func (c *Consumer) Lag() int64 {
+	var lag int64
+	for _, sub := range c.subs {
+		lag += sub.Lag()
+	}
+	return lag
+}
+
+// CheckProgress сообщает об ошибке, если цикл чтения не запущен или есть
+// отставание, а новых сообщений не было дольше stallTimeout.
+func (c *Consumer) CheckProgress(maxLag int64, stallTimeout time.Duration) error {
+	if !c.running.Load() {
+		return fmt.Errorf("consumer loop is not running")
+	}
+
+	lag := c.Lag()
+	idle := time.Since(time.Unix(0, c.activity.Load()))
+	if lag > 0 && idle > stallTimeout {
+		return fmt.Errorf("consumer stalled: lag=%d, no progress for %v", lag, idle.Round(time.Second))
+	}
+	if maxLag > 0 && lag > maxLag {
+		return fmt.Errorf("consumer lag %d exceeds %d", lag, maxLag)
+	}
+	return nil
+}
+
+// Close закрывает подписки и покидает группу. Вызывается после того, как
+// Start вернул управление.
+func (c *Consumer) Close() error {
+	var first error
+	for _, sub := range c.subs {
+		if err := sub.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
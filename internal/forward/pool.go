@@ -0,0 +1,274 @@
+package forward
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"transport/internal/metrics"
+	"transport/internal/model"
+	"transport/internal/queue"
+)
+
+type forwardJob struct {
+	msg       queue.Message
+	segment   model.Segment
+	attempt   int
+	notBefore time.Time
+}
+
+// forwardPool пересылает сегменты в канал ограниченным числом воркеров.
+// У каждого воркера своя очередь; при ordered=true все сегменты одного
+// messageId попадают к одному воркеру и пересылаются по порядку.
+type forwardPool struct {
+	queues  []*jobQueue
+	ordered bool
+	// ready, если задан, вызывается воркером перед выбором задания
+	ready func()
+	depth atomic.Int64
+	next  atomic.Uint64
+	wg    sync.WaitGroup
+}
+
+// jobQueue — очередь воркера с отдельным FIFO на каждый класс срочности.
+// Воркер всегда берёт задание самого срочного непустого класса, а ёмкость
+// ограничена по классам: заполненная очередь bulk блокирует только чтение
+// bulk-топика, и emergency-сегмент не ждёт за ней.
+type jobQueue struct {
+	mu     sync.Mutex
+	jobs   [][]forwardJob
+	slots  []chan struct{}
+	notify chan struct{}
+	closed bool
+}
+
+func newJobQueue(capacity int) *jobQueue {
+	q := &jobQueue{
+		jobs:   make([][]forwardJob, len(model.Priorities)),
+		slots:  make([]chan struct{}, len(model.Priorities)),
+		notify: make(chan struct{}, 1),
+	}
+	for i := range q.slots {
+		q.slots[i] = make(chan struct{}, capacity)
+	}
+	return q
+}
+
+// push ставит задание в FIFO его класса, блокируясь, пока класс заполнен.
+func (q *jobQueue) push(ctx context.Context, job forwardJob) bool {
+	class := job.segment.Priority.Rank()
+	select {
+	case q.slots[class] <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+
+	q.mu.Lock()
+	q.jobs[class] = append(q.jobs[class], job)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// pop возвращает задание самого срочного класса, ожидая, пока оно появится.
+// После close возвращает false, когда очередь опустела.
+func (q *jobQueue) pop() (forwardJob, bool) {
+	for {
+		q.mu.Lock()
+		for class, jobs := range q.jobs {
+			if len(jobs) == 0 {
+				continue
+			}
+			job := jobs[0]
+			q.jobs[class] = jobs[1:]
+			q.mu.Unlock()
+			<-q.slots[class]
+			return job, true
+		}
+		closed := q.closed
+		q.mu.Unlock()
+
+		if closed {
+			return forwardJob{}, false
+		}
+		<-q.notify
+	}
+}
+
+func (q *jobQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for _, jobs := range q.jobs {
+		n += len(jobs)
+	}
+	return n
+}
+
+func (q *jobQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	close(q.notify)
+}
+
+func newForwardPool(workers, queueSize int, ordered bool) *forwardPool {
+	if workers < 1 {
+		workers = 1
+	}
+	perWorker := queueSize / workers
+	if perWorker < 1 {
+		perWorker = 1
+	}
+
+	p := &forwardPool{
+		queues:  make([]*jobQueue, workers),
+		ordered: ordered,
+	}
+	for i := range p.queues {
+		p.queues[i] = newJobQueue(perWorker)
+	}
+	return p
+}
+
+// Run запускает воркеров. handle вызывается для каждого задания; Run
+// возвращает управление после Close и обработки уже принятых заданий.
+func (p *forwardPool) Run(handle func(forwardJob)) {
+	for _, q := range p.queues {
+		p.wg.Add(1)
+		go func(q *jobQueue) {
+			defer p.wg.Done()
+			for {
+				if p.ready != nil {
+					p.ready()
+				}
+				job, ok := q.pop()
+				if !ok {
+					return
+				}
+				p.depth.Add(-1)
+				metrics.ForwardQueueDepth.Dec()
+				handle(job)
+			}
+		}(q)
+	}
+}
+
+// Submit ставит задание в очередь воркера, блокируясь, пока очередь его
+// класса полна. Возвращает false, если ctx отменён раньше.
+func (p *forwardPool) Submit(ctx context.Context, job forwardJob) bool {
+	q := p.queues[p.pick(job.segment.MessageID)]
+
+	p.depth.Add(1)
+	metrics.ForwardQueueDepth.Inc()
+
+	if !q.push(ctx, job) {
+		p.depth.Add(-1)
+		metrics.ForwardQueueDepth.Dec()
+		return false
+	}
+	return true
+}
+
+func (p *forwardPool) pick(messageID string) int {
+	n := len(p.queues)
+	if p.ordered {
+		h := fnv.New32a()
+		h.Write([]byte(messageID))
+		return int(h.Sum32() % uint32(n))
+	}
+
+	// Без требования порядка выбираем наименее загруженную очередь
+	best := int(p.next.Add(1) % uint64(n))
+	bestLen := p.queues[best].len()
+	for i := range p.queues {
+		if l := p.queues[i].len(); l < bestLen {
+			best, bestLen = i, l
+		}
+	}
+	return best
+}
+
+func (p *forwardPool) Depth() int64 {
+	return p.depth.Load()
+}
+
+// Close закрывает очереди и ждёт завершения воркеров.
+func (p *forwardPool) Close() {
+	for _, q := range p.queues {
+		q.close()
+	}
+	p.wg.Wait()
+}
+
+// offsetTracker фиксирует смещение партиции только тогда, когда обработаны
+// все сообщения до него включительно, даже если воркеры завершают их не по порядку.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[topicPartition]*partitionOffsets
+}
+
+// topicPartition различает партиции с одинаковым номером в разных топиках
+// классов срочности.
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+type partitionOffsets struct {
+	pending []int64
+	done    map[int64]bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[topicPartition]*partitionOffsets)}
+}
+
+func (t *offsetTracker) Add(m queue.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := topicPartition{m.Topic, m.Partition}
+	p, ok := t.partitions[key]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]bool)}
+		t.partitions[key] = p
+	}
+	p.pending = append(p.pending, m.Offset)
+	if n := len(p.pending); n > 1 && p.pending[n-2] > m.Offset {
+		// После ребаланса смещения могут прийти заново с меньших значений
+		sort.Slice(p.pending, func(i, j int) bool { return p.pending[i] < p.pending[j] })
+	}
+}
+
+// Done отмечает сообщение обработанным и, если непрерывный префикс
+// продвинулся, вызывает commit для последнего сообщения префикса.
+// commit выполняется под блокировкой, чтобы фиксации не обгоняли друг друга.
+func (t *offsetTracker) Done(m queue.Message, commit func(queue.Message)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[topicPartition{m.Topic, m.Partition}]
+	if !ok {
+		return
+	}
+	p.done[m.Offset] = true
+
+	last := int64(-1)
+	for len(p.pending) > 0 && p.done[p.pending[0]] {
+		last = p.pending[0]
+		delete(p.done, last)
+		p.pending = p.pending[1:]
+	}
+
+	if last >= 0 {
+		commit(queue.Message{Topic: m.Topic, Partition: m.Partition, Offset: last})
+	}
+}
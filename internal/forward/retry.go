@@ -0,0 +1,187 @@
+package forward
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+	"transport/internal/metrics"
+	"transport/internal/queue"
+)
+
+// Заголовки, которыми сегмент сопровождается в retry- и DLQ-топиках
+const (
+	headerAttempt       = "x-attempt"
+	headerNextAttempt   = "x-next-attempt"
+	headerLastError     = "x-last-error"
+	headerOriginalTopic = "x-original-topic"
+)
+
+// retryState читает номер попытки и время, раньше которого сегмент
+// нельзя пересылать повторно. Для сообщений без заголовков — 0 и нулевое время.
+func retryState(m queue.Message) (attempt int, notBefore time.Time) {
+	if v, ok := m.Headers[headerAttempt]; ok {
+		attempt, _ = strconv.Atoi(v)
+	}
+	if v, ok := m.Headers[headerNextAttempt]; ok {
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			notBefore = time.UnixMilli(ms)
+		}
+	}
+	return attempt, notBefore
+}
+
+// withoutRetryHeaders возвращает заголовки сообщения без служебных retry-заголовков.
+func withoutRetryHeaders(headers map[string]string) map[string]string {
+	out := make(map[string]string, len(headers)+4)
+	for k, v := range headers {
+		switch k {
+		case headerAttempt, headerNextAttempt, headerLastError, headerOriginalTopic:
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// retryDelay — задержка перед попыткой attempt: RetryDelay * 2^(attempt-1), не более RetryDelayMax.
+func (c *Consumer) retryDelay(attempt int) time.Duration {
+	delay := c.cfg.RetryDelay
+	for i := 1; i < attempt && delay < c.cfg.RetryDelayMax; i++ {
+		delay *= 2
+	}
+	if delay > c.cfg.RetryDelayMax {
+		delay = c.cfg.RetryDelayMax
+	}
+	return delay
+}
+
+// reroute переносит сегмент, который не удалось переслать, в retry-топик
+// или, после исчерпания попыток, в DLQ. Запись повторяется, пока не
+// удастся или не будет отменён ctx.
+func (c *Consumer) reroute(ctx context.Context, job forwardJob, cause error) bool {
+	attempt := job.attempt + 1
+	// Retry-топик тоже разделён по классам, чтобы повторы bulk не задерживали срочные
+	topic := queue.PriorityTopic(c.cfg.RetryTopic, job.segment.Priority)
+	next := time.Now().Add(c.retryDelay(attempt))
+	if attempt >= c.cfg.ForwardMaxAttempts {
+		topic = c.cfg.DLQTopic
+	}
+
+	original := job.msg.Topic
+	if v, ok := job.msg.Headers[headerOriginalTopic]; ok {
+		original = v
+	}
+
+	headers := withoutRetryHeaders(job.msg.Headers)
+	headers[headerAttempt] = strconv.Itoa(attempt)
+	headers[headerNextAttempt] = strconv.FormatInt(next.UnixMilli(), 10)
+	headers[headerLastError] = cause.Error()
+	headers[headerOriginalTopic] = original
+
+	msg := queue.Message{
+		Topic:   topic,
+		Key:     job.msg.Key,
+		Value:   job.msg.Value,
+		Headers: headers,
+	}
+
+	retry := newBackoff(c.cfg.ConsumerBackoffMin, c.cfg.ConsumerBackoffMax)
+	for {
+		err := c.queue.Write(ctx, msg)
+		if err == nil {
+			break
+		}
+		delay := retry.Next()
+		log.Printf("[Consumer] Failed to write segment %d of message %s to %s: %v. Retrying in %v",
+			job.segment.SegmentIndex, job.segment.MessageID, topic, err, delay)
+		if !sleepCtx(ctx, delay) {
+			return false
+		}
+	}
+
+	if topic == c.cfg.DLQTopic {
+		log.Printf("[Consumer] Segment %d of message %s moved to DLQ %s after %d attempt(s): %v",
+			job.segment.SegmentIndex, job.segment.MessageID, topic, attempt, cause)
+		metrics.SegmentsDropped.WithLabelValues(metrics.Sender(job.segment.Sender), metrics.ReasonDeadLetter).Inc()
+	} else {
+		log.Printf("[Consumer] Segment %d of message %s scheduled for retry #%d at %s: %v",
+			job.segment.SegmentIndex, job.segment.MessageID, attempt, next.Format(time.RFC3339), cause)
+	}
+	return true
+}
+
+// DLQ переотправляет сегменты из dead-letter топика обратно в основной топик.
+type DLQ struct {
+	queue   queue.SegmentQueue
+	topic   string
+	target  string
+	groupID string
+	busy    chan struct{}
+}
+
+func NewDLQ(q queue.SegmentQueue, dlqTopic, targetTopic, groupID string) *DLQ {
+	return &DLQ{
+		queue:   q,
+		topic:   dlqTopic,
+		target:  targetTopic,
+		groupID: groupID,
+		busy:    make(chan struct{}, 1),
+	}
+}
+
+// Redrive переносит до limit сообщений (limit <= 0 — все доступные) из DLQ
+// в основной топик со сброшенным счётчиком попыток. Сегмент возвращается в
+// топик своего класса из заголовка x-original-topic. Сообщение фиксируется
+// в DLQ только после успешной записи в основной топик.
+func (d *DLQ) Redrive(ctx context.Context, limit int) (int, error) {
+	select {
+	case d.busy <- struct{}{}:
+		defer func() { <-d.busy }()
+	default:
+		return 0, fmt.Errorf("redrive already in progress")
+	}
+
+	sub := d.queue.Subscribe(d.topic, d.groupID)
+	defer sub.Close()
+
+	moved := 0
+	for limit <= 0 || moved < limit {
+		// DLQ считается вычитанным, если за idle-интервал новых сообщений нет
+		fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		m, err := sub.Fetch(fetchCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return moved, ctx.Err()
+			}
+			if fetchCtx.Err() != nil {
+				break
+			}
+			return moved, fmt.Errorf("fetch from %s: %w", d.topic, err)
+		}
+
+		target := d.target
+		if v := m.Headers[headerOriginalTopic]; v != "" {
+			target = v
+		}
+		err = d.queue.Write(ctx, queue.Message{
+			Topic:   target,
+			Key:     m.Key,
+			Value:   m.Value,
+			Headers: withoutRetryHeaders(m.Headers),
+		})
+		if err != nil {
+			return moved, fmt.Errorf("write to %s: %w", target, err)
+		}
+
+		if err := sub.Commit(ctx, m); err != nil {
+			return moved, fmt.Errorf("commit %s offset %d: %w", d.topic, m.Offset, err)
+		}
+		moved++
+	}
+
+	log.Printf("[DLQ] Redriven %d segment(s) from %s to %s", moved, d.topic, d.target)
+	return moved, nil
+}
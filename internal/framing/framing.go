@@ -0,0 +1,137 @@
+// Package framing выбирает, как сегменты и ACK упаковываются для канала
+// (CHANNEL_FRAMING или режим соседа из графа контактов), и разбирает входящие тела /transferSegment и
+// /transferAck по их Content-Type.
+package framing
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"time"
+	"transport/internal/bundle"
+	"transport/internal/ccsds"
+	"transport/internal/config"
+	"transport/internal/model"
+	"transport/internal/wire"
+)
+
+// Режимы кадрирования канала
+const (
+	ModeWire   = "wire"
+	ModeBundle = "bundle"
+	ModeCCSDS  = "ccsds"
+)
+
+// AcceptedContentTypes перечисляет типы тел, которые понимают Decode*.
+var AcceptedContentTypes = []string{
+	wire.ContentTypeJSON,
+	wire.ContentTypeBinary,
+	bundle.ContentType,
+	ccsds.ContentType,
+}
+
+// modeOf возвращает режим канала: mode соседа или CHANNEL_FRAMING.
+func modeOf(cfg *config.Config, mode string) string {
+	if mode == "" {
+		return cfg.ChannelFraming
+	}
+	return mode
+}
+
+// EncodeSegment кодирует сегмент для канала в режиме mode (пусто —
+// CHANNEL_FRAMING) и возвращает Content-Type.
+func EncodeSegment(cfg *config.Config, mode string, seg model.Segment) ([]byte, string, error) {
+	switch modeOf(cfg, mode) {
+	case ModeBundle:
+		data, err := bundle.WrapSegment(cfg.Bundle, seg)
+		return data, bundle.ContentType, err
+	case ModeCCSDS:
+		data, err := ccsds.FrameSegment(cfg.SpacePacket, seg)
+		return data, ccsds.ContentType, err
+	}
+	data, err := wire.EncodeSegment(cfg.WireFormat, seg)
+	return data, cfg.WireFormat.ContentType(), err
+}
+
+// EncodeAck кодирует ACK для канала в режиме mode (пусто — CHANNEL_FRAMING)
+// и возвращает Content-Type.
+func EncodeAck(cfg *config.Config, mode string, ack model.Ack) ([]byte, string, error) {
+	switch modeOf(cfg, mode) {
+	case ModeBundle:
+		data, err := bundle.WrapAck(cfg.Bundle, ack)
+		return data, bundle.ContentType, err
+	case ModeCCSDS:
+		data, err := ccsds.FrameAck(cfg.SpacePacket, ack)
+		return data, ccsds.ContentType, err
+	}
+	data, err := wire.EncodeAck(cfg.WireFormat, ack)
+	return data, cfg.WireFormat.ContentType(), err
+}
+
+// DecodeSegment разбирает сегмент, пришедший с Content-Type contentType.
+// Режим кадрирования определяется по типу тела, а не по CHANNEL_FRAMING,
+// поэтому узлы с разными режимами понимают друг друга.
+func DecodeSegment(contentType string, data []byte) (model.Segment, error) {
+	mediaType, err := mediaTypeOf(contentType)
+	if err != nil {
+		return model.Segment{}, err
+	}
+	if mediaType == ccsds.ContentType {
+		return ccsds.DeframeSegment(data)
+	}
+	adu, err := unwrap(mediaType, data)
+	if err != nil {
+		return model.Segment{}, err
+	}
+	return wire.DecodeSegment(adu)
+}
+
+// DecodeAck разбирает ACK, пришедший с Content-Type contentType.
+func DecodeAck(contentType string, data []byte) (model.Ack, error) {
+	mediaType, err := mediaTypeOf(contentType)
+	if err != nil {
+		return model.Ack{}, err
+	}
+	if mediaType == ccsds.ContentType {
+		return ccsds.DeframeAck(data)
+	}
+	adu, err := unwrap(mediaType, data)
+	if err != nil {
+		return model.Ack{}, err
+	}
+	return wire.DecodeAck(adu)
+}
+
+func mediaTypeOf(contentType string) (string, error) {
+	// Пустой Content-Type трактуется как JSON — так отправляют старые клиенты
+	if contentType == "" {
+		return wire.ContentTypeJSON, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", wire.ErrUnsupportedMediaType, err)
+	}
+	return mediaType, nil
+}
+
+// unwrap снимает с тела кадрирование BPv7 и проверяет соответствие формата wire.
+func unwrap(mediaType string, data []byte) ([]byte, error) {
+	if mediaType == bundle.ContentType {
+		adu, b, err := bundle.Unwrap(data, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("[Bundle] Received bundle %s #%d from %s",
+			b.Primary.CreationTime.Format(time.RFC3339), b.Primary.Sequence, b.Primary.Source)
+		return adu, nil
+	}
+
+	format, err := wire.FormatFromContentType(mediaType)
+	if err != nil {
+		return nil, err
+	}
+	if err := wire.CheckFormat(format, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
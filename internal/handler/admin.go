@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"transport/internal/config"
+	"transport/internal/forward"
+	"transport/internal/model"
+	"transport/internal/service"
+)
+
+type AdminHandler struct {
+	Webhooks *service.WebhookRegistry
+	DLQ      *forward.DLQ
+	Config   *config.Config
+}
+
+func NewAdminHandler(webhooks *service.WebhookRegistry, dlq *forward.DLQ, cfg *config.Config) *AdminHandler {
+	return &AdminHandler{
+		Webhooks: webhooks,
+		DLQ:      dlq,
+		Config:   cfg,
+	}
+}
+
+// Authorize проверяет Bearer-токен администратора. Без ADMIN_TOKEN
+// административные запросы отклоняются.
+func (h *AdminHandler) Authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if h.check(w, token) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// AuthorizeStream проверяет токен администратора для потока событий.
+// Браузерные EventSource и WebSocket не умеют ставить заголовок
+// Authorization, поэтому токен принимается и в параметре access_token.
+func (h *AdminHandler) AuthorizeStream(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("access_token")
+		}
+		if h.check(w, token) {
+			next(w, r)
+		}
+	}
+}
+
+// check сверяет token с ADMIN_TOKEN и при отказе отвечает клиенту.
+func (h *AdminHandler) check(w http.ResponseWriter, token string) bool {
+	if h.Config.AdminToken == "" {
+		http.Error(w, "admin API is disabled: ADMIN_TOKEN is not set", http.StatusForbidden)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.Config.AdminToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func (h *AdminHandler) RegisterWebhook(w http.ResponseWriter, r *http.Request) {
+	var hook model.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		http.Error(w, "invalid webhook", http.StatusBadRequest)
+		return
+	}
+
+	if hook.Sender == "" || !isHTTPURL(hook.URL) {
+		http.Error(w, "sender and http(s) url are required", http.StatusBadRequest)
+		return
+	}
+	if !h.Config.WebhookAllowed(hook.URL) {
+		log.Printf("[ERROR] Webhook URL for %s is not in WEBHOOK_ALLOWED_HOSTS: %q", hook.Sender, hook.URL)
+		http.Error(w, "webhook url host is not allowed", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Webhooks.Register(hook); err != nil {
+		log.Printf("[ERROR] Failed to register webhook for %s: %v", hook.Sender, err)
+		http.Error(w, "failed to register webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *AdminHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.Webhooks.List())
+}
+
+func (h *AdminHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	sender := r.URL.Query().Get("sender")
+	if sender == "" {
+		http.Error(w, "sender is required", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := h.Webhooks.Unregister(sender, r.URL.Query().Get("url"))
+	if err != nil {
+		log.Printf("[ERROR] Failed to remove webhook for %s: %v", sender, err)
+		http.Error(w, "failed to remove webhook", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "webhook not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RedriveDLQ возвращает сегменты из dead-letter топика в основной топик.
+// Необязательный параметр limit ограничивает число переносимых сообщений.
+func (h *AdminHandler) RedriveDLQ(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	log.Printf("[HTTP] DLQ redrive requested (limit=%d)", limit)
+
+	moved, err := h.DLQ.Redrive(r.Context(), limit)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		log.Printf("[ERROR] DLQ redrive stopped after %d segment(s): %v", moved, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"redriven": moved, "error": err.Error()})
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]int{"redriven": moved})
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"transport/internal/config"
+	"transport/internal/service"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	keepAliveInterval = 15 * time.Second
+	wsWriteTimeout    = 10 * time.Second
+)
+
+type EventsHandler struct {
+	Bus      *service.EventBus
+	Config   *config.Config
+	upgrader websocket.Upgrader
+}
+
+func NewEventsHandler(bus *service.EventBus, cfg *config.Config) *EventsHandler {
+	h := &EventsHandler{Bus: bus, Config: cfg}
+	h.upgrader = websocket.Upgrader{CheckOrigin: h.allowedOrigin}
+	return h
+}
+
+// allowedOrigin пропускает запросы без Origin (не из браузера), с того же
+// хоста и с origin-ов из EVENTS_ALLOWED_ORIGINS — UI mission control
+// открывается с другого origin.
+func (h *EventsHandler) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return slices.Contains(h.Config.EventsOrigins, origin)
+}
+
+func eventFilter(r *http.Request) service.EventFilter {
+	q := r.URL.Query()
+	return service.EventFilter{
+		MessageID: q.Get("messageId"),
+		Sender:    q.Get("sender"),
+	}
+}
+
+// Stream отдаёт события в формате Server-Sent Events.
+func (h *EventsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	if !h.allowedOrigin(r) {
+		log.Printf("[Events] Rejected SSE client from origin %q", r.Header.Get("Origin"))
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
+
+	filter := eventFilter(r)
+	events, cancel := h.Bus.Subscribe(filter)
+	defer cancel()
+
+	log.Printf("[Events] SSE client connected (messageId=%q, sender=%q)", filter.MessageID, filter.Sender)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Println("[Events] SSE client disconnected")
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				log.Printf("[Events] Failed to marshal event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// WebSocket отдаёт те же события JSON-сообщениями по WebSocket.
+func (h *EventsHandler) WebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[Events] WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	filter := eventFilter(r)
+	events, cancel := h.Bus.Subscribe(filter)
+	defer cancel()
+
+	log.Printf("[Events] WebSocket client connected (messageId=%q, sender=%q)", filter.MessageID, filter.Sender)
+
+	// Читаем входящие кадры только чтобы обнаружить закрытие соединения
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-closed:
+			log.Println("[Events] WebSocket client disconnected")
+			return
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		case e, ok := <-events:
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down"),
+					time.Now().Add(wsWriteTimeout))
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		}
+	}
+}
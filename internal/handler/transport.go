@@ -2,33 +2,80 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+	"transport/internal/auth"
 	"transport/internal/config"
-	"transport/internal/kafka"
+	"transport/internal/framing"
+	"transport/internal/keyring"
+	"transport/internal/metrics"
 	"transport/internal/model"
+	"transport/internal/queue"
+	"transport/internal/routing"
 	"transport/internal/service"
+	"transport/internal/wire"
+
+	"github.com/gorilla/mux"
 )
 
 type TransportHandler struct {
-	Producer    *kafka.Producer
+	Producer    queue.Publisher
 	Reassembler *service.Reassembler
 	Config      *config.Config
 	AckTracker  *service.AckTracker
+	Outbox      *service.Outbox
+	Webhooks    *service.WebhookRegistry
+	Events      *service.EventBus
+	Router      *routing.Router
+	FanOut      *service.FanOut
+	Keyring     *keyring.Keyring
+	Auth        *auth.Authenticator
+
+	draining atomic.Bool
 }
 
-func NewTransportHandler(prod *kafka.Producer, reas *service.Reassembler, cfg *config.Config, tracker *service.AckTracker) *TransportHandler {
+func NewTransportHandler(prod queue.Publisher, reas *service.Reassembler, cfg *config.Config, tracker *service.AckTracker, outbox *service.Outbox, webhooks *service.WebhookRegistry, events *service.EventBus, router *routing.Router, fanout *service.FanOut, keys *keyring.Keyring, signer *auth.Authenticator) *TransportHandler {
 	return &TransportHandler{
 		Producer:    prod,
 		Reassembler: reas,
 		Config:      cfg,
 		AckTracker:  tracker,
+		Outbox:      outbox,
+		Webhooks:    webhooks,
+		Events:      events,
+		Router:      router,
+		FanOut:      fanout,
+		Keyring:     keys,
+		Auth:        signer,
 	}
 }
 
+// StopAccepting переводит обработчик в режим остановки: новые сообщения
+// отклоняются с 503, уже принятые запросы дорабатывают.
+func (h *TransportHandler) StopAccepting() {
+	h.draining.Store(true)
+}
+
+func (h *TransportHandler) Draining() bool {
+	return h.draining.Load()
+}
+
 func (h *TransportHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	log.Println("[HTTP] /sendMessage called")
 
+	if h.draining.Load() {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "transport is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
 	var req model.SendMessageRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("[ERROR] Invalid request body: %v", err)
@@ -36,75 +83,481 @@ func (h *TransportHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("[INFO] Received message %s from %s", req.MessageID, req.Sender)
+	if req.CallbackURL != "" && !isHTTPURL(req.CallbackURL) {
+		log.Printf("[ERROR] Invalid callback URL for message %s: %q", req.MessageID, req.CallbackURL)
+		http.Error(w, "invalid callbackUrl", http.StatusBadRequest)
+		return
+	}
+	if req.CallbackURL != "" && !h.Config.WebhookAllowed(req.CallbackURL) {
+		log.Printf("[ERROR] Callback URL for message %s is not in WEBHOOK_ALLOWED_HOSTS: %q", req.MessageID, req.CallbackURL)
+		http.Error(w, "callbackUrl host is not allowed", http.StatusBadRequest)
+		return
+	}
 
-	segments := service.SplitMessageToSegments(req, h.Config.SegmentSize)
+	if req.RedLength != nil && (*req.RedLength < 0 || *req.RedLength > len(req.Message)) {
+		log.Printf("[ERROR] Invalid redLength %d for message %s of %d bytes", *req.RedLength, req.MessageID, len(req.Message))
+		http.Error(w, "invalid redLength", http.StatusBadRequest)
+		return
+	}
 
-	for _, segment := range segments {
-		err := h.Producer.SendSegment(segment)
+	priority, err := model.ParsePriority(string(req.Priority))
+	if err != nil {
+		log.Printf("[ERROR] Invalid priority for message %s: %v", req.MessageID, err)
+		http.Error(w, "invalid priority", http.StatusBadRequest)
+		return
+	}
+	req.Priority = priority
+
+	expiresAt, err := messageExpiry(req, time.Now())
+	if err != nil {
+		log.Printf("[ERROR] Invalid lifetime for message %s: %v", req.MessageID, err)
+		http.Error(w, "invalid lifetime: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	destinations, err := h.destinations(req)
+	if err != nil {
+		log.Printf("[ERROR] Invalid destination for message %s: %v", req.MessageID, err)
+		http.Error(w, "invalid destination: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("[INFO] Received message %s from %s to %s (priority %s)",
+		req.MessageID, req.Sender, strings.Join(destinations, ", "), req.Priority)
+	if !expiresAt.IsZero() {
+		log.Printf("[INFO] Message %s expires at %s", req.MessageID, expiresAt.Format(time.RFC3339))
+	}
+
+	if len(destinations) == 1 {
+		tracked, err := h.publish(r, req, req.MessageID, destinations[0], expiresAt)
 		if err != nil {
-			log.Printf("[ERROR] Failed to send segment %d: %v", segment.SegmentIndex, err)
 			http.Error(w, "failed to send", http.StatusInternalServerError)
 			return
 		}
-		log.Printf("[INFO] Segment %d/%d sent for message %s", segment.SegmentIndex, segment.TotalSegments, segment.MessageID)
+		// ACK по целиком green-сообщению не придёт: итоговый статус — sent
+		if !tracked {
+			if err := service.SendFinalStatus(h.Outbox, h.FanOut, req.MessageID, model.StatusSent, h.Config.NodeID, h.Config); err != nil {
+				log.Printf("[ERROR] Failed to queue final status for %s: %v", req.MessageID, err)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		return
 	}
 
-	h.AckTracker.Track(req.MessageID, segments)
-	log.Printf("[INFO] Tracking started for message %s", req.MessageID)
+	// Каждому получателю уходит своя копия со своим ACK и повторами;
+	// итоговый статус отправитель получит один, когда завершатся все копии
+	h.FanOut.Start(req.MessageID, destinations)
+	results := make(map[string]string, len(destinations))
+	for _, dst := range destinations {
+		id := service.PartID(req.MessageID, dst)
+		tracked, err := h.publish(r, req, id, dst, expiresAt)
+		switch {
+		case err != nil:
+			results[id] = model.StatusError
+		case !tracked:
+			results[id] = model.StatusSent
+		}
+	}
+	if len(results) == len(destinations) && !hasStatus(results, model.StatusSent) {
+		h.FanOut.Drop(req.MessageID)
+		http.Error(w, "failed to send", http.StatusInternalServerError)
+		return
+	}
+	for id, status := range results {
+		if err := service.SendFinalStatus(h.Outbox, h.FanOut, id, status, h.Config.NodeID, h.Config); err != nil {
+			log.Printf("[ERROR] Failed to queue final status for %s: %v", id, err)
+		}
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// seal шифрует содержимое копии сообщения до сегментации. Red- и
+// green-часть шифруются отдельно, чтобы потеря green-сегментов не мешала
+// получателю расшифровать red-часть; RedLength пересчитывается под
+// шифротекст. Каждая копия рассылки шифруется под своим идентификатором.
+func (h *TransportHandler) seal(req *model.SendMessageRequest) error {
+	red, green := req.Message, ""
+	if req.RedLength != nil {
+		red, green = req.Message[:*req.RedLength], req.Message[*req.RedLength:]
+	}
+
+	var err error
+	if red != "" {
+		if red, err = h.Keyring.Seal(red, keyring.AAD(req.Sender, req.MessageID, keyring.PartRed)); err != nil {
+			return err
+		}
+	}
+	if green != "" {
+		if green, err = h.Keyring.Seal(green, keyring.AAD(req.Sender, req.MessageID, keyring.PartGreen)); err != nil {
+			return err
+		}
+	}
+
+	req.Message = red + green
+	if req.RedLength != nil {
+		n := len(red)
+		req.RedLength = &n
+	}
+	return nil
+}
+
+// destinations раскрывает адреса получателей: группа заменяется узлами из
+// ADDRESS_GROUPS, повторы убираются. Без адресов — ROUTE_DESTINATION.
+// Этот узел в группе пропускается, а явно указанный — ошибка.
+func (h *TransportHandler) destinations(req model.SendMessageRequest) ([]string, error) {
+	addrs := req.Destinations
+	if req.Destination != "" {
+		addrs = append([]string{req.Destination}, addrs...)
+	}
+	if len(addrs) == 0 {
+		addrs = []string{h.Config.RouteDestination}
+	}
+
+	seen := make(map[string]bool)
+	var nodes []string
+	for _, addr := range addrs {
+		members, group := h.Config.AddressGroups[addr]
+		if !group {
+			if addr == "" || addr == h.Config.NodeID {
+				return nil, fmt.Errorf("%q is not a remote node", addr)
+			}
+			members = []string{addr}
+		}
+		for _, node := range members {
+			if node == h.Config.NodeID || seen[node] {
+				continue
+			}
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("no remote recipients")
+	}
+	return nodes, nil
+}
+
+// publish сегментирует копию сообщения с идентификатором id для узла
+// destination, ставит её в очередь и начинает отслеживать ACK.
+// tracked=false — копия целиком green и не отслеживается.
+func (h *TransportHandler) publish(r *http.Request, req model.SendMessageRequest, id, destination string, expiresAt time.Time) (tracked bool, err error) {
+	req.MessageID = id
+	if h.Keyring.Enabled() {
+		if err := h.seal(&req); err != nil {
+			log.Printf("[ERROR] Failed to encrypt message %s: %v", id, err)
+			return false, err
+		}
+	}
+	h.Webhooks.Watch(id, req.Sender, req.CallbackURL)
+
+	segments := service.SplitMessageToSegments(req, h.Config.SegmentSize)
+	for i := range segments {
+		if !expiresAt.IsZero() {
+			segments[i].ExpiresAt = expiresAt.UnixMilli()
+		}
+		segments[i].Source = h.Config.NodeID
+		segments[i].Destination = destination
+		if h.Config.CustodyTransfer && !segments[i].IsGreen() {
+			segments[i].Custodian = h.Config.NodeID
+		}
+	}
+	metrics.SegmentsPerMessage.WithLabelValues(metrics.Sender(req.Sender), metrics.OutcomeOK).Observe(float64(len(segments)))
+	h.Webhooks.Notify(id, model.EventAccepted, len(segments), -1)
+
+	if err := h.Producer.SendSegments(r.Context(), segments); err != nil {
+		log.Printf("[ERROR] Failed to publish message %s: %v", id, err)
+		h.Webhooks.Notify(id, model.EventFailed, len(segments), -1)
+		return false, err
+	}
+	log.Printf("[INFO] %d segment(s) published for message %s", len(segments), id)
+
+	for _, segment := range segments {
+		h.Events.Publish(model.Event{
+			Type:          model.StreamSegmentSent,
+			MessageID:     segment.MessageID,
+			Sender:        segment.Sender,
+			SegmentIndex:  segment.SegmentIndex,
+			TotalSegments: segment.TotalSegments,
+			LastConfirmed: -1,
+		})
+	}
+
+	// Повторно отправляется только red-часть; green-сегменты уходят один раз
+	if red := segments[:len(segments)-greenCount(segments)]; len(red) > 0 {
+		h.AckTracker.Track(id, red)
+		log.Printf("[INFO] Tracking started for message %s (%d red segment(s))", id, len(red))
+		tracked = true
+	} else {
+		log.Printf("[INFO] Message %s is entirely green, not tracked", id)
+	}
+	h.Webhooks.Notify(id, model.EventSegmentsPublished, len(segments), -1)
+	if !tracked {
+		h.Webhooks.Notify(id, model.EventSent, len(segments), -1)
+	}
+	return tracked, nil
+}
+
+func hasStatus(results map[string]string, status string) bool {
+	for _, s := range results {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *TransportHandler) TransferSegment(w http.ResponseWriter, r *http.Request) {
 	log.Println("[HTTP] /transferSegment called")
 
-	var seg model.Segment
-	if err := json.NewDecoder(r.Body).Decode(&seg); err != nil {
-		log.Printf("[ERROR] Failed to unmarshal segment: %v", err)
-		http.Error(w, "invalid segment", http.StatusBadRequest)
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	seg, err := framing.DecodeSegment(r.Header.Get("Content-Type"), data)
+	if err != nil {
+		writeWireError(w, "segment", err)
+		return
+	}
+	if err := h.Auth.VerifySegment(seg); err != nil {
+		rejectFrame(w, "segment", seg.Signer, err)
 		return
 	}
 
 	log.Printf("[INFO] Received segment %d/%d for message %s", seg.SegmentIndex, seg.TotalSegments, seg.MessageID)
 
-	h.Reassembler.AddSegment(seg)
+	if !h.Router.IsLocal(seg.Destination) {
+		h.relaySegment(w, r, seg)
+		return
+	}
+
+	// Узел назначения принимает ответственность, когда сегмент записан в
+	// журнал сборки на диске; без журнала копии отправителя освобождает
+	// итоговый ACK после доставки сообщения. Отброшенный сегмент не
+	// считается принятым, и его повтор пройдёт проверку подписи
+	if h.Reassembler.AddSegment(seg) {
+		h.Auth.Consume(seg.Auth)
+		if h.Reassembler.Durable() {
+			h.acceptCustody(seg, seg.Custodian)
+		}
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// relaySegment ставит сегмент, адресованный другому узлу, в очередь
+// сегментов: оттуда consumer передаст его следующему узлу маршрута, когда
+// откроется сеанс связи. Пока сегмент не записан в очередь, канал получает
+// ошибку и повторит передачу.
+func (h *TransportHandler) relaySegment(w http.ResponseWriter, r *http.Request, seg model.Segment) {
+	// Узел, который не принимает ответственность, оставляет хранителем
+	// предыдущий узел
+	custodian := seg.Custodian
+	if h.Config.AcceptsCustody() && custodian != "" {
+		seg.Custodian = h.Router.NodeID()
+	}
+
+	if err := h.Producer.SendSegments(r.Context(), []model.Segment{seg}); err != nil {
+		log.Printf("[Relay] Failed to queue segment %d of message %s for %s: %v", seg.SegmentIndex, seg.MessageID, seg.Destination, err)
+		metrics.SegmentsRelayed.WithLabelValues(metrics.Sender(seg.Sender), metrics.OutcomeError).Inc()
+		http.Error(w, "failed to relay", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[Relay] Segment %d of message %s queued for %s", seg.SegmentIndex, seg.MessageID, seg.Destination)
+	metrics.SegmentsRelayed.WithLabelValues(metrics.Sender(seg.Sender), metrics.OutcomeOK).Inc()
+	h.Auth.Consume(seg.Auth)
+	if h.Config.AcceptsCustody() {
+		h.acceptCustody(seg, custodian)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// acceptCustody сообщает предыдущему хранителю custodian, что сегмент
+// надёжно сохранён этим узлом и его копию можно освободить.
+func (h *TransportHandler) acceptCustody(seg model.Segment, custodian string) {
+	if !h.Config.CustodyTransfer || custodian == "" {
+		return
+	}
+	log.Printf("[Custody] Accepted custody of segment %d of message %s from %s", seg.SegmentIndex, seg.MessageID, custodian)
+	metrics.CustodySignals.WithLabelValues(metrics.Sender(seg.Sender), metrics.OutcomeOK).Inc()
+	go service.SendAckToChannel(model.Ack{
+		MessageID:            seg.MessageID,
+		LastConfirmedSegment: -1,
+		Destination:          custodian,
+		Custody:              true,
+		Segments:             []int{seg.SegmentIndex},
+	}, h.Config, h.Router, h.Auth)
+}
+
 func (h *TransportHandler) TransferAck(w http.ResponseWriter, r *http.Request) {
 	log.Println("[HTTP] /transferAck called")
 
-	var ack model.Ack
-	if err := json.NewDecoder(r.Body).Decode(&ack); err != nil {
-		log.Printf("[ERROR] Invalid ACK: %v", err)
-		http.Error(w, "invalid ack", http.StatusBadRequest)
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	ack, err := framing.DecodeAck(r.Header.Get("Content-Type"), data)
+	if err != nil {
+		writeWireError(w, "ack", err)
+		return
+	}
+	// Без проверки подписи поддельный Final=true ACK завершил бы сообщение
+	if err := h.Auth.VerifyAck(ack); err != nil {
+		rejectFrame(w, "ack", ack.Signer, err)
 		return
 	}
 
 	log.Printf("[INFO] Received ACK for message %s, lastConfirmed=%d", ack.MessageID, ack.LastConfirmedSegment)
+	// ACK учитывается трекером в памяти сразу, и его повтор уже ничего не
+	// изменит, поэтому кадр отмечается принятым до обработки
+	h.Auth.Consume(ack.Auth)
+
+	if !h.Router.IsLocal(ack.Destination) {
+		log.Printf("[Relay] ACK for message %s is addressed to %s, forwarding", ack.MessageID, ack.Destination)
+		go service.SendAckToChannel(ack, h.Config, h.Router, h.Auth)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if ack.Custody {
+		h.AckTracker.HandleCustody(ack)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
 	resend, done, fail := h.AckTracker.HandleAck(ack)
 
 	if done {
-		log.Printf("[INFO] All segments confirmed for %s. Forwarding final ACK to app-earth.", ack.MessageID)
-		go service.SendFinalAck(ack, false, h.Config)
+		log.Printf("[INFO] All segments confirmed for %s. Forwarding final ACK to application of %s.", ack.MessageID, ack.Destination)
+		if err := service.SendFinalAck(h.Outbox, h.FanOut, ack, false, h.Config); err != nil {
+			log.Printf("[ERROR] Failed to queue final ACK for %s: %v", ack.MessageID, err)
+		}
+		h.Webhooks.Notify(ack.MessageID, model.EventDelivered, 0, ack.LastConfirmedSegment)
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
 	if fail {
-		log.Printf("[WARN] Max retries exceeded for message %s. Sending error ACK.", ack.MessageID)
-		go service.SendFinalAck(ack, true, h.Config)
+		log.Printf("[WARN] Delivery of message %s failed. Sending error ACK.", ack.MessageID)
+		if err := service.SendFinalAck(h.Outbox, h.FanOut, ack, true, h.Config); err != nil {
+			log.Printf("[ERROR] Failed to queue error ACK for %s: %v", ack.MessageID, err)
+		}
+		h.Webhooks.Notify(ack.MessageID, model.EventFailed, 0, ack.LastConfirmedSegment)
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	for _, seg := range resend {
-		log.Printf("[INFO] Resending segment %d of message %s", seg.SegmentIndex, seg.MessageID)
-		_ = h.Producer.SendSegment(seg)
+	if len(resend) > 0 {
+		h.Webhooks.Notify(ack.MessageID, model.EventPartiallyAcked, resend[0].TotalSegments, ack.LastConfirmedSegment)
+	}
+
+	// Публикует фоновый цикл AckTracker в порядке срочности сообщений
+	h.AckTracker.ScheduleResend(resend, ack.LastConfirmedSegment)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *TransportHandler) CancelMessage(w http.ResponseWriter, r *http.Request) {
+	messageID := mux.Vars(r)["messageId"]
+	log.Printf("[HTTP] cancel requested for message %s", messageID)
+
+	// Отмена разосланного сообщения отменяет все ещё отслеживаемые копии
+	ids, fanout := h.FanOut.Parts(messageID)
+	if !fanout {
+		ids = []string{messageID}
+	}
+
+	cancelled := 0
+	for _, id := range ids {
+		tracked, ok := h.AckTracker.Cancel(id)
+		if !ok {
+			continue
+		}
+		cancelled++
+		if err := service.SendFinalStatus(h.Outbox, h.FanOut, id, model.StatusCancelled, tracked.Source(), h.Config); err != nil {
+			log.Printf("[ERROR] Failed to queue cancel ACK for %s: %v", id, err)
+		}
+		h.Webhooks.Notify(id, model.EventCancelled, tracked.TotalSegments, tracked.LastConfirmed)
+	}
+	if cancelled == 0 {
+		http.Error(w, "message not tracked", http.StatusNotFound)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
+
+func (h *TransportHandler) OutboxStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{
+		"pending": h.Outbox.Pending(),
+	})
+}
+
+// messageExpiry вычисляет срок жизни сообщения из Lifetime и Deadline.
+// Нулевое время означает, что срок не задан.
+func messageExpiry(req model.SendMessageRequest, now time.Time) (time.Time, error) {
+	var expiresAt time.Time
+	if req.Lifetime != "" {
+		lifetime, err := time.ParseDuration(req.Lifetime)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if lifetime <= 0 {
+			return time.Time{}, fmt.Errorf("lifetime must be positive, got %v", lifetime)
+		}
+		expiresAt = now.Add(lifetime)
+	}
+	if req.Deadline != nil && (expiresAt.IsZero() || req.Deadline.Before(expiresAt)) {
+		expiresAt = *req.Deadline
+	}
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return time.Time{}, fmt.Errorf("deadline %s has already passed", expiresAt.Format(time.RFC3339))
+	}
+	return expiresAt, nil
+}
+
+func greenCount(segments []model.Segment) int {
+	if len(segments) == 0 {
+		return 0
+	}
+	return segments[0].GreenSegments
+}
+
+// writeWireError отвечает 415 на неподдерживаемый Content-Type и 400 на
+// тело, которое не удалось разобрать.
+func writeWireError(w http.ResponseWriter, kind string, err error) {
+	log.Printf("[ERROR] Invalid %s: %v", kind, err)
+	if errors.Is(err, wire.ErrUnsupportedMediaType) {
+		w.Header().Set("Accept", strings.Join(framing.AcceptedContentTypes, ", "))
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	http.Error(w, "invalid "+kind+": "+err.Error(), http.StatusBadRequest)
+}
+
+// rejectFrame отвечает 401 на кадр без подписи, с неверной подписью или
+// повторно принятый.
+func rejectFrame(w http.ResponseWriter, kind, signer string, err error) {
+	reason := "invalid_signature"
+	switch {
+	case errors.Is(err, auth.ErrUnsigned):
+		reason = "unsigned"
+	case errors.Is(err, auth.ErrUnknownPeer):
+		reason = "unknown_signer"
+	case errors.Is(err, auth.ErrStale):
+		reason = "stale"
+	case errors.Is(err, auth.ErrReplay):
+		reason = "replay"
+	}
+	log.Printf("[Auth] Rejected %s signed by %q: %v", kind, signer, err)
+	metrics.FramesRejected.WithLabelValues(kind, reason).Inc()
+	http.Error(w, fmt.Sprintf("%s rejected: %v", kind, err), http.StatusUnauthorized)
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"transport/internal/auth"
+	"transport/internal/config"
+	"transport/internal/model"
+	"transport/internal/routing"
+	"transport/internal/wire"
+)
+
+// flakyPublisher отказывает в записи первые failures раз.
+type flakyPublisher struct {
+	failures int
+	queued   []model.Segment
+}
+
+func (p *flakyPublisher) SendSegments(ctx context.Context, segments []model.Segment) error {
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("queue unavailable")
+	}
+	p.queued = append(p.queued, segments...)
+	return nil
+}
+
+// newRelay создаёт обработчик ретранслятора mro между earth и mars.
+func newRelay(t *testing.T, prod *flakyPublisher) *TransportHandler {
+	t.Helper()
+	graph := filepath.Join(t.TempDir(), "graph.json")
+	const data = `{"nodes": [{"id": "earth"}, {"id": "mro"}, {"id": "mars", "url": "http://channel-mars"}],
+		"contacts": [{"from": "mro", "to": "mars", "start": "2026-01-01T00:00:00Z", "end": "2036-01-01T00:00:00Z", "dataRate": 1000}]}`
+	if err := os.WriteFile(graph, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	router := routing.NewRouter("mro", graph)
+	if err := router.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := auth.New("mro", map[string]string{
+		"earth": "earth-secret-0123456789",
+		"mro":   "mro-secret-0123456789",
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &TransportHandler{
+		Producer: prod,
+		Config:   &config.Config{NodeID: "mro", QueueBackend: "memory"},
+		Router:   router,
+		Auth:     signer,
+	}
+}
+
+// Сегмент, который ретранслятор не смог записать в очередь, канал повторяет;
+// повтор того же кадра не должен отклоняться как повтор принятого
+func TestRelayRetryAfterQueueError(t *testing.T) {
+	prod := &flakyPublisher{failures: 1}
+	h := newRelay(t, prod)
+
+	earth, err := auth.New("earth", map[string]string{"earth": "earth-secret-0123456789"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg, err := earth.SignSegment(model.Segment{
+		Sender:        "alice",
+		MessageID:     "m1",
+		TotalSegments: 1,
+		Payload:       "hello",
+		Source:        "earth",
+		Destination:   "mars",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := wire.EncodeSegment(wire.FormatBinary, seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func() int {
+		r := httptest.NewRequest(http.MethodPost, "/transferSegment", bytes.NewReader(body))
+		r.Header.Set("Content-Type", wire.ContentTypeBinary)
+		w := httptest.NewRecorder()
+		h.TransferSegment(w, r)
+		return w.Code
+	}
+
+	if code := send(); code != http.StatusInternalServerError {
+		t.Fatalf("first attempt = %d, want 500", code)
+	}
+	if code := send(); code != http.StatusOK {
+		t.Fatalf("retry after queue error = %d, want 200", code)
+	}
+	if len(prod.queued) != 1 {
+		t.Fatalf("queued %d segment(s), want 1", len(prod.queued))
+	}
+	// После записи в очередь тот же кадр — уже повтор
+	if code := send(); code != http.StatusUnauthorized {
+		t.Fatalf("replay after success = %d, want 401", code)
+	}
+}
@@ -0,0 +1,115 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type CheckFunc func(ctx context.Context) error
+
+type CheckResult struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Latency string `json:"latency"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Checker выполняет зарегистрированные проверки зависимостей параллельно.
+type Checker struct {
+	mu      sync.RWMutex
+	checks  map[string]CheckFunc
+	timeout time.Duration
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		checks:  make(map[string]CheckFunc),
+		timeout: timeout,
+	}
+}
+
+func (c *Checker) Register(name string, check CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+func (c *Checker) Run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	c.mu.RLock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	c.mu.RUnlock()
+	sort.Strings(names)
+
+	report := Report{Status: "ok", Checks: make(map[string]CheckResult, len(names))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, name := range names {
+		c.mu.RLock()
+		check := c.checks[name]
+		c.mu.RUnlock()
+
+		wg.Add(1)
+		go func(name string, check CheckFunc) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+			res := CheckResult{Status: "ok", Latency: time.Since(start).Round(time.Millisecond).String()}
+			if err != nil {
+				res.Status = "fail"
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Checks[name] = res
+			if err != nil {
+				report.Status = "fail"
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+
+	wg.Wait()
+	return report
+}
+
+// HTTPReachable проверяет, что сервис по baseURL отвечает на HTTP-запрос.
+// Любой HTTP-ответ (включая 404) считается признаком доступности.
+func HTTPReachable(client *http.Client, baseURL string) CheckFunc {
+	return func(ctx context.Context) error {
+		if baseURL == "" {
+			return fmt.Errorf("url not configured")
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("status code: %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,218 @@
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"transport/internal/config"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Client хранит общие для producer-ов и consumer-ов параметры подключения
+// к кластеру: брокеры, TLS, SASL и настройки записи.
+type Client struct {
+	Brokers   []string
+	Dialer    *kafka.Dialer
+	Transport *kafka.Transport
+
+	compression  kafka.Compression
+	batchSize    int
+	batchTimeout time.Duration
+	requiredAcks kafka.RequiredAcks
+}
+
+func NewClient(cfg *config.Config) (*Client, error) {
+	brokers := splitBrokers(cfg.KafkaBrokers)
+	if len(brokers) == 0 {
+		return nil, errors.New("KAFKA_BROKERS is empty")
+	}
+
+	tlsConfig, err := buildTLS(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("kafka TLS: %w", err)
+	}
+
+	mechanism, err := buildSASL(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("kafka SASL: %w", err)
+	}
+
+	compression, err := parseCompression(cfg.KafkaCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	acks, err := parseRequiredAcks(cfg.KafkaRequiredAcks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		Brokers: brokers,
+		Dialer: &kafka.Dialer{
+			ClientID:      cfg.KafkaClientID,
+			Timeout:       10 * time.Second,
+			DualStack:     true,
+			TLS:           tlsConfig,
+			SASLMechanism: mechanism,
+		},
+		Transport: &kafka.Transport{
+			ClientID: cfg.KafkaClientID,
+			TLS:      tlsConfig,
+			SASL:     mechanism,
+		},
+		compression:  compression,
+		batchSize:    cfg.KafkaBatchSize,
+		batchTimeout: cfg.KafkaBatchTimeout,
+		requiredAcks: acks,
+	}, nil
+}
+
+// newWriter создаёт writer с настройками кластера. Пустой topic означает,
+// что топик задаётся в каждом сообщении.
+func (c *Client) newWriter(topic string, balancer kafka.Balancer) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(c.Brokers...),
+		Topic:        topic,
+		Balancer:     balancer,
+		Transport:    c.Transport,
+		Compression:  c.compression,
+		BatchSize:    c.batchSize,
+		BatchTimeout: c.batchTimeout,
+		RequiredAcks: c.requiredAcks,
+	}
+}
+
+func (c *Client) newClient() *kafka.Client {
+	return &kafka.Client{
+		Addr:      kafka.TCP(c.Brokers...),
+		Timeout:   10 * time.Second,
+		Transport: c.Transport,
+	}
+}
+
+func (c *Client) newReader(topic, groupID string) *kafka.Reader {
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers: c.Brokers,
+		Topic:   topic,
+		GroupID: groupID,
+		Dialer:  c.Dialer,
+	})
+}
+
+// Ping проверяет, что хотя бы один брокер доступен и отвечает на запрос метаданных.
+func (c *Client) Ping(ctx context.Context) error {
+	var lastErr error
+	for _, addr := range c.Brokers {
+		conn, err := c.Dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		_, err = conn.Brokers()
+		conn.Close()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+	}
+
+	return fmt.Errorf("no reachable broker: %w", lastErr)
+}
+
+func splitBrokers(list string) []string {
+	var brokers []string
+	for _, b := range strings.Split(list, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	return brokers
+}
+
+func buildTLS(cfg *config.Config) (*tls.Config, error) {
+	if !cfg.KafkaTLSEnabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.KafkaTLSInsecureSkipVerify,
+	}
+
+	if cfg.KafkaTLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.KafkaTLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.KafkaTLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.KafkaTLSCertFile != "" || cfg.KafkaTLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.KafkaTLSCertFile, cfg.KafkaTLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func buildSASL(cfg *config.Config) (sasl.Mechanism, error) {
+	switch strings.ToUpper(cfg.KafkaSASLMechanism) {
+	case "":
+		return nil, nil
+	case "PLAIN":
+		return plain.Mechanism{Username: cfg.KafkaSASLUsername, Password: cfg.KafkaSASLPassword}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, cfg.KafkaSASLUsername, cfg.KafkaSASLPassword)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, cfg.KafkaSASLUsername, cfg.KafkaSASLPassword)
+	default:
+		return nil, fmt.Errorf("unsupported mechanism %q", cfg.KafkaSASLMechanism)
+	}
+}
+
+func parseCompression(name string) (kafka.Compression, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("unsupported KAFKA_COMPRESSION %q", name)
+	}
+}
+
+func parseRequiredAcks(level string) (kafka.RequiredAcks, error) {
+	switch strings.ToLower(level) {
+	case "", "all", "-1":
+		return kafka.RequireAll, nil
+	case "one", "1":
+		return kafka.RequireOne, nil
+	case "none", "0":
+		return kafka.RequireNone, nil
+	default:
+		return 0, fmt.Errorf("unsupported KAFKA_REQUIRED_ACKS %q", level)
+	}
+}
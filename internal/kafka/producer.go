@@ -2,49 +2,231 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/segmentio/kafka-go"
+	"transport/internal/metrics"
 	"transport/internal/model"
+	"transport/internal/queue"
+	"transport/internal/wire"
 )
 
+const partitionsCacheTTL = time.Minute
+
 type Producer struct {
-	writer *kafka.Writer
+	writer   *kafka.Writer
+	client   *Client
+	kc       *kafka.Client
+	topic    string
+	format   wire.Format
+	balancer kafka.Balancer
+
+	mu         sync.Mutex
+	partitions map[string]cachedPartitions
+}
+
+type cachedPartitions struct {
+	ids []int
+	at  time.Time
 }
 
-func NewProducer(brokers []string, topic string) *Producer {
+func NewProducer(client *Client, topic string, format wire.Format) *Producer {
+	// Hash по ключу messageId: все сегменты сообщения попадают в одну
+	// партицию, и одиночная запись совпадает с пакетной по расположению.
+	// Topic у writer не задан: каждый класс срочности пишется в свой топик
+	balancer := &kafka.Hash{}
 	return &Producer{
-		writer: &kafka.Writer{
-			Addr:     kafka.TCP(brokers...),
-			Topic:    topic,
-			Balancer: &kafka.LeastBytes{},
-		},
+		client:     client,
+		writer:     client.newWriter("", balancer),
+		kc:         client.newClient(),
+		topic:      topic,
+		format:     format,
+		balancer:   balancer,
+		partitions: make(map[string]cachedPartitions),
+	}
+}
+
+// SendSegments публикует сегменты, группируя их по messageId. Сегменты
+// одного сообщения уходят одним produce-запросом в одну партицию как единый
+// record batch, который брокер принимает или отклоняет целиком. Kafka-go не
+// поддерживает идемпотентный и транзакционный producer, поэтому повтор после
+// сетевой ошибки может дать дубликаты — Reassembler их отбрасывает.
+// Сообщения публикуются в топики своих классов срочности, срочные — первыми.
+// При частичном сбое возвращается *queue.BatchError.
+func (p *Producer) SendSegments(ctx context.Context, segments []model.Segment) error {
+	if len(segments) == 0 {
+		return nil
 	}
+
+	order, byMessage := queue.GroupByMessage(segments)
+	queue.SortByPriority(order, byMessage)
+	failed := make(map[string]error)
+	for _, id := range order {
+		if err := p.sendMessageBatch(ctx, id, byMessage[id]); err != nil {
+			failed[id] = err
+		}
+	}
+
+	if len(failed) > 0 {
+		return &queue.BatchError{Messages: failed}
+	}
+	return nil
 }
 
-func (p *Producer) SendSegment(segment model.Segment) error {
-	data, err := json.Marshal(segment)
+func (p *Producer) sendMessageBatch(ctx context.Context, messageID string, segments []model.Segment) error {
+	sender := segments[0].Sender
+	topic := queue.PriorityTopic(p.topic, segments[0].Priority)
+
+	records := make([]kafka.Record, 0, len(segments))
+	indexes := make([]int, 0, len(segments))
+	for _, seg := range segments {
+		data, err := wire.EncodeSegment(p.format, seg)
+		if err != nil {
+			return fmt.Errorf("encode segment %d: %w", seg.SegmentIndex, err)
+		}
+		records = append(records, kafka.Record{
+			Key:   kafka.NewBytes([]byte(messageID)),
+			Value: kafka.NewBytes(data),
+		})
+		indexes = append(indexes, seg.SegmentIndex)
+	}
+
+	start := time.Now()
+	err := p.produce(ctx, topic, messageID, records, indexes)
+	outcome := metrics.OutcomeOK
+	if err != nil {
+		outcome = metrics.OutcomeError
+	}
+	metrics.KafkaWriteLatency.WithLabelValues(metrics.Sender(sender), outcome).Observe(time.Since(start).Seconds())
+	metrics.SegmentsProduced.WithLabelValues(metrics.Sender(sender), outcome).Add(float64(len(segments)))
+
 	if err != nil {
-		log.Printf("[Kafka] Failed to marshal segment %d of message %s: %v",
-			segment.SegmentIndex, segment.MessageID, err)
+		log.Printf("[Kafka] Failed to publish %d segment(s) of message %s: %v", len(segments), messageID, err)
 		return err
 	}
 
-	msg := kafka.Message{
-		Key:   []byte(segment.MessageID),
-		Value: data,
+	log.Printf("[Kafka] Published %d segment(s) of message %s to %s in one batch (%v)",
+		len(segments), messageID, topic, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// produce пишет записи одним запросом. indexes — индексы сегментов записей:
+// по ним *RecordsError называет отклонённые брокером сегменты.
+func (p *Producer) produce(ctx context.Context, topic, key string, records []kafka.Record, indexes []int) error {
+	partitions, err := p.topicPartitions(ctx, topic)
+	if err != nil {
+		return err
 	}
+	partition := p.balancer.Balance(kafka.Message{Key: []byte(key)}, partitions...)
 
-	err = p.writer.WriteMessages(context.Background(), msg)
+	resp, err := p.kc.Produce(ctx, &kafka.ProduceRequest{
+		Topic:        topic,
+		Partition:    partition,
+		RequiredAcks: p.writer.RequiredAcks,
+		Compression:  p.writer.Compression,
+		Records:      kafka.NewRecordReader(records...),
+	})
 	if err != nil {
-		log.Printf("[Kafka] Failed to send segment %d of message %s: %v",
-			segment.SegmentIndex, segment.MessageID, err)
+		p.invalidatePartitions(topic)
 		return err
 	}
+	if resp.Error != nil {
+		p.invalidatePartitions(topic)
+		return resp.Error
+	}
+	if len(resp.RecordErrors) > 0 {
+		rejected := make(map[int]error, len(resp.RecordErrors))
+		for i, recErr := range resp.RecordErrors {
+			if i >= 0 && i < len(indexes) {
+				i = indexes[i]
+			}
+			rejected[i] = recErr
+		}
+		return &RecordsError{Segments: rejected}
+	}
+	return nil
+}
+
+// RecordsError — брокер отклонил часть записей пакета; Segments хранит
+// ошибку по индексу каждого отклонённого сегмента.
+type RecordsError struct {
+	Segments map[int]error
+}
+
+func (e *RecordsError) Error() string {
+	indexes := make([]int, 0, len(e.Segments))
+	for i := range e.Segments {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
 
-	log.Printf("[Kafka] Segment %d/%d of message %s successfully sent",
-		segment.SegmentIndex, segment.TotalSegments, segment.MessageID)
+	msg := fmt.Sprintf("%d segment(s) rejected:", len(indexes))
+	for n, i := range indexes {
+		if n > 0 {
+			msg += ";"
+		}
+		msg += fmt.Sprintf(" segment %d: %v", i, e.Segments[i])
+	}
+	return msg
+}
 
-	return nil
+// Unwrap отдаёт ошибки всех отклонённых сегментов для errors.Is и errors.As.
+func (e *RecordsError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Segments))
+	for _, err := range e.Segments {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func (p *Producer) topicPartitions(ctx context.Context, topic string) ([]int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c, ok := p.partitions[topic]; ok && time.Since(c.at) < partitionsCacheTTL {
+		return c.ids, nil
+	}
+
+	meta, err := p.kc.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, fmt.Errorf("metadata for %s: %w", topic, err)
+	}
+	if len(meta.Topics) == 0 {
+		return nil, fmt.Errorf("topic %s not found", topic)
+	}
+	if meta.Topics[0].Error != nil {
+		return nil, fmt.Errorf("topic %s: %w", topic, meta.Topics[0].Error)
+	}
+
+	partitions := make([]int, 0, len(meta.Topics[0].Partitions))
+	for _, part := range meta.Topics[0].Partitions {
+		partitions = append(partitions, part.ID)
+	}
+	sort.Ints(partitions)
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("topic %s has no partitions", topic)
+	}
+
+	p.partitions[topic] = cachedPartitions{ids: partitions, at: time.Now()}
+	return partitions, nil
+}
+
+func (p *Producer) invalidatePartitions(topic string) {
+	p.mu.Lock()
+	delete(p.partitions, topic)
+	p.mu.Unlock()
+}
+
+// Ping проверяет доступность брокеров, в которые пишет producer.
+func (p *Producer) Ping(ctx context.Context) error {
+	return p.client.Ping(ctx)
+}
+
+// Close дожидается отправки буферизованных сообщений и закрывает writer.
+func (p *Producer) Close() error {
+	return p.writer.Close()
 }
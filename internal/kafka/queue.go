@@ -0,0 +1,97 @@
+package kafka
+
+import (
+	"context"
+	"transport/internal/queue"
+	"transport/internal/wire"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Queue — реализация queue.SegmentQueue поверх Kafka.
+type Queue struct {
+	*Producer
+	client *Client
+	router *kafka.Writer
+}
+
+var _ queue.SegmentQueue = (*Queue)(nil)
+
+func NewQueue(client *Client, segmentTopic string, format wire.Format) *Queue {
+	return &Queue{
+		Producer: NewProducer(client, segmentTopic, format),
+		client:   client,
+		// Без Topic: топик задаётся в каждом сообщении
+		router: client.newWriter("", &kafka.Hash{}),
+	}
+}
+
+func (q *Queue) Write(ctx context.Context, msgs ...queue.Message) error {
+	out := make([]kafka.Message, 0, len(msgs))
+	for _, m := range msgs {
+		km := kafka.Message{
+			Topic: m.Topic,
+			Key:   m.Key,
+			Value: m.Value,
+		}
+		for k, v := range m.Headers {
+			km.Headers = append(km.Headers, kafka.Header{Key: k, Value: []byte(v)})
+		}
+		out = append(out, km)
+	}
+	return q.router.WriteMessages(ctx, out...)
+}
+
+func (q *Queue) Subscribe(topic, groupID string) queue.Subscription {
+	return &subscription{reader: q.client.newReader(topic, groupID)}
+}
+
+func (q *Queue) Close() error {
+	routerErr := q.router.Close()
+	if err := q.Producer.Close(); err != nil {
+		return err
+	}
+	return routerErr
+}
+
+type subscription struct {
+	reader *kafka.Reader
+}
+
+func (s *subscription) Fetch(ctx context.Context) (queue.Message, error) {
+	m, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		return queue.Message{}, err
+	}
+
+	msg := queue.Message{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       m.Key,
+		Value:     m.Value,
+	}
+	if len(m.Headers) > 0 {
+		msg.Headers = make(map[string]string, len(m.Headers))
+		for _, h := range m.Headers {
+			msg.Headers[h.Key] = string(h.Value)
+		}
+	}
+	return msg, nil
+}
+
+func (s *subscription) Commit(ctx context.Context, msg queue.Message) error {
+	return s.reader.CommitMessages(ctx, kafka.Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+	})
+}
+
+func (s *subscription) Lag() int64 {
+	return s.reader.Stats().Lag
+}
+
+func (s *subscription) Close() error {
+	return s.reader.Close()
+}
@@ -0,0 +1,204 @@
+// Package keyring шифрует содержимое сообщений на отправителе и
+// расшифровывает его на получателе (AES-GCM), чтобы по Kafka, каналу и
+// ретрансляторам шли только шифротексты. Ключи читаются из локального
+// файла; для ротации в файл добавляется новый ключ и делается активным,
+// старые остаются, пока в пути могут быть зашифрованные ими сообщения.
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// envelopePrefix открывает зашифрованное содержимое:
+// "$enc1$<keyID>$<base64(nonce||ciphertext)>".
+const envelopePrefix = "$enc1$"
+
+// ErrDisabled — ключи не настроены.
+var ErrDisabled = errors.New("keyring is not configured")
+
+// Части сообщения, которые шифруются отдельно
+const (
+	PartRed   = "red"
+	PartGreen = "green"
+)
+
+// AAD связывает шифротекст с отправителем, сообщением и его частью: часть,
+// вырезанная из одного сообщения, не расшифруется в составе другого.
+func AAD(sender, messageID, part string) string {
+	return sender + "\x00" + messageID + "\x00" + part
+}
+
+// Keyring — набор ключей по идентификаторам и активный ключ для шифрования.
+type Keyring struct {
+	path string
+
+	mu     sync.RWMutex
+	keys   map[string]cipher.AEAD
+	active string
+	loaded []byte
+}
+
+func New(path string) *Keyring {
+	return &Keyring{path: path, keys: make(map[string]cipher.AEAD)}
+}
+
+// Enabled сообщает, задан ли файл ключей.
+func (k *Keyring) Enabled() bool {
+	return k.path != ""
+}
+
+// keyringFile — файл ключей вида
+//
+//	{"active": "2026-10", "keys": {"2026-09": "<base64>", "2026-10": "<base64>"}}
+//
+// Ключи — 16, 24 или 32 байта (AES-128/192/256) в base64.
+type keyringFile struct {
+	Active string            `json:"active"`
+	Keys   map[string]string `json:"keys"`
+}
+
+// Reload перечитывает файл ключей. При ошибке действуют прежние ключи.
+func (k *Keyring) Reload() error {
+	if !k.Enabled() {
+		return nil
+	}
+
+	data, err := os.ReadFile(k.path)
+	if err != nil {
+		return err
+	}
+	k.mu.RLock()
+	unchanged := bytes.Equal(data, k.loaded)
+	k.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	var raw keyringFile
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("parse %s: %w", k.path, err)
+	}
+
+	keys := make(map[string]cipher.AEAD, len(raw.Keys))
+	for id, encoded := range raw.Keys {
+		if id == "" || strings.Contains(id, "$") {
+			return fmt.Errorf("parse %s: invalid key id %q", k.path, id)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("parse %s: key %s: %w", k.path, id, err)
+		}
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return fmt.Errorf("parse %s: key %s: %w", k.path, id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return fmt.Errorf("parse %s: key %s: %w", k.path, id, err)
+		}
+		keys[id] = aead
+	}
+	if _, ok := keys[raw.Active]; !ok {
+		return fmt.Errorf("parse %s: active key %q is not in keys", k.path, raw.Active)
+	}
+
+	k.mu.Lock()
+	rotated := k.active != "" && k.active != raw.Active
+	k.keys, k.active, k.loaded = keys, raw.Active, data
+	k.mu.Unlock()
+
+	if rotated {
+		log.Printf("[Keyring] Active key rotated to %s", raw.Active)
+	}
+	log.Printf("[Keyring] Loaded %d key(s) from %s, active %s", len(keys), k.path, raw.Active)
+	return nil
+}
+
+// Run периодически перечитывает файл ключей, пока не будет отменён ctx.
+func (k *Keyring) Run(ctx context.Context, interval time.Duration) {
+	if !k.Enabled() || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := k.Reload(); err != nil {
+				log.Printf("[Keyring] Failed to reload keyring: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Seal шифрует plaintext активным ключом со случайным nonce. aad
+// аутентифицируется вместе с шифротекстом, но не шифруется.
+func (k *Keyring) Seal(plaintext, aad string) (string, error) {
+	k.mu.RLock()
+	id, aead := k.active, k.keys[k.active]
+	k.mu.RUnlock()
+	if aead == nil {
+		return "", ErrDisabled
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+	return envelopePrefix + id + "$" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open расшифровывает один фрагмент, созданный Seal, ключом из его заголовка.
+func (k *Keyring) Open(envelope, aad string) (string, error) {
+	id, body, ok := strings.Cut(strings.TrimPrefix(envelope, envelopePrefix), "$")
+	if !ok || !strings.HasPrefix(envelope, envelopePrefix) {
+		return "", errors.New("malformed envelope")
+	}
+
+	k.mu.RLock()
+	aead := k.keys[id]
+	k.mu.RUnlock()
+	if aead == nil {
+		if !k.Enabled() {
+			return "", ErrDisabled
+		}
+		return "", fmt.Errorf("unknown key %q", id)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return "", fmt.Errorf("envelope: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("envelope too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("key %s: %w", id, err)
+	}
+	return string(plaintext), nil
+}
+
+// IsSealed сообщает, зашифровано ли содержимое.
+func IsSealed(content string) bool {
+	return strings.HasPrefix(content, envelopePrefix)
+}
@@ -0,0 +1,153 @@
+package keyring
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeKeys(t *testing.T, path, active string, ids ...string) {
+	t.Helper()
+	raw := keyringFile{Active: active, Keys: make(map[string]string)}
+	for _, id := range ids {
+		// Ключ выводится из идентификатора, чтобы один id давал один ключ
+		raw.Keys[id] = base64.StdEncoding.EncodeToString([]byte(strings.Repeat(id, 32)[:32]))
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newKeyring(t *testing.T, active string, ids ...string) (*Keyring, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeys(t, path, active, ids...)
+	k := New(path)
+	if err := k.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	return k, path
+}
+
+func TestSealOpen(t *testing.T) {
+	k, _ := newKeyring(t, "k1", "k1")
+	aad := AAD("alice", "m1", PartRed)
+
+	sealed, err := k.Seal("rover telemetry", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || !strings.HasPrefix(sealed, "$enc1$k1$") {
+		t.Fatalf("unexpected envelope %q", sealed)
+	}
+	if strings.Contains(sealed, "rover") {
+		t.Fatal("envelope contains plaintext")
+	}
+
+	got, err := k.Open(sealed, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "rover telemetry" {
+		t.Fatalf("Open = %q", got)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	k, path := newKeyring(t, "k1", "k1")
+	aad := AAD("alice", "m1", PartRed)
+	old, err := k.Seal("before rotation", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeKeys(t, path, "k2", "k1", "k2")
+	if err := k.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh, err := k.Seal("after rotation", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(fresh, "$enc1$k2$") {
+		t.Fatalf("new envelope not sealed with active key: %q", fresh)
+	}
+	// Сообщения, зашифрованные прежним ключом, ещё в пути и должны открываться
+	if got, err := k.Open(old, aad); err != nil || got != "before rotation" {
+		t.Fatalf("Open(old) = %q, %v", got, err)
+	}
+}
+
+func TestUnknownKey(t *testing.T) {
+	k, path := newKeyring(t, "k1", "k1")
+	aad := AAD("alice", "m1", PartRed)
+	sealed, err := k.Seal("retired", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeKeys(t, path, "k2", "k2")
+	if err := k.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Open(sealed, aad); err == nil || !strings.Contains(err.Error(), `unknown key "k1"`) {
+		t.Fatalf("Open error = %v, want unknown key", err)
+	}
+}
+
+func TestTamper(t *testing.T) {
+	k, _ := newKeyring(t, "k1", "k1")
+	aad := AAD("alice", "m1", PartRed)
+	sealed, err := k.Seal("do not touch", aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(sealed)
+	i := len(body) - 5
+	if body[i] == 'A' {
+		body[i] = 'B'
+	} else {
+		body[i] = 'A'
+	}
+
+	tests := []struct {
+		name     string
+		envelope string
+		aad      string
+	}{
+		{"ciphertext", string(body), aad},
+		{"other sender", sealed, AAD("mallory", "m1", PartRed)},
+		{"other message", sealed, AAD("alice", "m2", PartRed)},
+		{"other part", sealed, AAD("alice", "m1", PartGreen)},
+		{"malformed", "$enc1$k1", aad},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := k.Open(tt.envelope, tt.aad); err == nil {
+				t.Fatalf("Open accepted tampered envelope: %q", got)
+			}
+		})
+	}
+}
+
+func TestReloadKeepsKeysOnError(t *testing.T) {
+	k, path := newKeyring(t, "k1", "k1")
+	if err := os.WriteFile(path, []byte(`{"active":"k9","keys":{}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Reload(); err == nil {
+		t.Fatal("Reload accepted a keyring without its active key")
+	}
+	if _, err := k.Seal("still works", AAD("alice", "m1", PartRed)); err != nil {
+		t.Fatalf("Seal after failed reload: %v", err)
+	}
+}
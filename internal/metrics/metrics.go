@@ -0,0 +1,223 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "transport"
+
+// Исходы, используемые в метке outcome
+const (
+	OutcomeOK         = "ok"
+	OutcomeError      = "error"
+	OutcomeRejected   = "rejected"
+	OutcomeInvalid    = "invalid"
+	OutcomeStored     = "stored"
+	OutcomeDuplicate  = "duplicate"
+	OutcomeSuccess    = "success"
+	OutcomeTimeout    = "timeout"
+	OutcomeCancelled  = "cancelled"
+	OutcomeProgress   = "progress"
+	OutcomeNoProgress = "no_progress"
+	OutcomeExpired    = "expired"
+)
+
+// Этапы, на которых обнаружено истечение срока жизни, для метки stage
+const (
+	StageConsumer    = "consumer"
+	StageReassembler = "reassembler"
+	StageAckTracker  = "ack_tracker"
+)
+
+// Причины отбрасывания сегментов для метки reason метрики SegmentsDropped
+const (
+	ReasonEmptyMessageID    = "empty_message_id"
+	ReasonInvalidEncoding   = "invalid_encoding"
+	ReasonBufferFull        = "buffer_full"
+	ReasonEvicted           = "evicted"
+	ReasonGreenLost         = "green_lost"
+	ReasonGreenNotForwarded = "green_not_forwarded"
+	ReasonReassemblyTimeout = "reassembly_timeout"
+	ReasonDeadLetter        = "dead_letter"
+)
+
+// OtherSender — значение метки sender для отправителей вне METRICS_SENDERS.
+const OtherSender = "other"
+
+// senders — отправители, которые попадают в метку sender как есть. Имя
+// отправителя задаёт клиент, поэтому без ограничения число рядов метрик
+// не ограничено.
+var senders atomic.Pointer[map[string]bool]
+
+// SetSenders задаёт отправителей, которые различаются в метке sender.
+func SetSenders(list []string) {
+	set := make(map[string]bool, len(list))
+	for _, s := range list {
+		set[s] = true
+	}
+	senders.Store(&set)
+}
+
+// Sender возвращает значение метки sender для отправителя s: сам s, если он
+// в списке METRICS_SENDERS, иначе OtherSender. Пустой s остаётся пустым —
+// так помечаются сегменты, отправителя которых не удалось прочитать.
+func Sender(s string) string {
+	if s == "" {
+		return ""
+	}
+	if set := senders.Load(); set != nil && (*set)[s] {
+		return s
+	}
+	return OtherSender
+}
+
+var expiryLabels = []string{"sender", "stage"}
+
+var segmentLabels = []string{"sender", "outcome"}
+
+var (
+	SegmentsProduced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "segments_produced_total",
+		Help:      "Segments written to the segment queue.",
+	}, segmentLabels)
+
+	SegmentsConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "segments_consumed_total",
+		Help:      "Segments read from the segment queue.",
+	}, segmentLabels)
+
+	SegmentsForwarded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "segments_forwarded_total",
+		Help:      "Segments forwarded to the channel service.",
+	}, segmentLabels)
+
+	SegmentsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "segments_received_total",
+		Help:      "Segments received from the channel for reassembly.",
+	}, segmentLabels)
+
+	SegmentsDuplicate = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "segments_duplicate_total",
+		Help:      "Received segments that were already buffered.",
+	}, segmentLabels)
+
+	SegmentsResent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "segments_resent_total",
+		Help:      "Segments republished after an ACK without full confirmation.",
+	}, segmentLabels)
+
+	SegmentsRelayed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "segments_relayed_total",
+		Help:      "Segments received for another node and queued for the next hop.",
+	}, segmentLabels)
+
+	SegmentsReleased = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "segments_custody_released_total",
+		Help:      "Tracked segments released by the sender after the next hop accepted custody.",
+	}, segmentLabels)
+
+	CustodySignals = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "custody_signals_total",
+		Help:      "Custody signals sent upstream for segments stored by this node.",
+	}, segmentLabels)
+
+	MessagesDecrypted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_decrypted_total",
+		Help:      "Encrypted messages opened after reassembly, by outcome.",
+	}, segmentLabels)
+
+	FramesRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "frames_rejected_total",
+		Help:      "Segments and ACKs rejected by signature verification, by reason.",
+	}, []string{"kind", "reason"})
+
+	SegmentsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "segments_dropped_total",
+		Help:      "Segments dropped, by reason.",
+	}, []string{"sender", "reason"})
+
+	DeliveryLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "delivery_latency_seconds",
+		Help:      "Time from publishing a message to its final status.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 14),
+	}, segmentLabels)
+
+	AckRoundTrip = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ack_round_trip_seconds",
+		Help:      "Time from the last (re)send of a message to the next ACK for it.",
+		Buckets:   prometheus.ExponentialBuckets(0.25, 2, 14),
+	}, segmentLabels)
+
+	SegmentsPerMessage = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "segments_per_message",
+		Help:      "Number of segments a message was split into.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	}, segmentLabels)
+
+	KafkaWriteLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_write_latency_seconds",
+		Help:      "Latency of writes to the Kafka segment topic.",
+		Buckets:   prometheus.DefBuckets,
+	}, segmentLabels)
+)
+
+var (
+	SegmentsExpired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "segments_expired_total",
+		Help:      "Segments dropped because their message lifetime ran out; stage holds where.",
+	}, expiryLabels)
+
+	MessagesExpired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_expired_total",
+		Help:      "Messages abandoned because their lifetime ran out; stage holds where.",
+	}, expiryLabels)
+)
+
+var ForwardQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "forward_queue_depth",
+	Help:      "Segments waiting in the channel forwarding worker queues.",
+})
+
+// RegisterGauges регистрирует gauge-и, значения которых читаются из
+// компонентов в момент сбора метрик.
+func RegisterGauges(trackedMessages, bufferedMessages, bufferedSegments func() float64) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tracked_messages",
+		Help:      "Messages in flight awaiting ACKs in the AckTracker.",
+	}, trackedMessages)
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "reassembly_buffer_messages",
+		Help:      "Partially received messages held by the Reassembler.",
+	}, bufferedMessages)
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "reassembly_buffer_segments",
+		Help:      "Segments held by the Reassembler for incomplete messages.",
+	}, bufferedSegments)
+}
@@ -0,0 +1,46 @@
+package model
+
+import "fmt"
+
+// Priority — класс срочности сообщения. Определяет порядок публикации,
+// пересылки в канал, повторной отправки и вытеснения из буфера сборки.
+type Priority string
+
+const (
+	PriorityEmergency Priority = "emergency"
+	PriorityCommand   Priority = "command"
+	PriorityTelemetry Priority = "telemetry"
+	PriorityBulk      Priority = "bulk"
+)
+
+// Priorities перечисляет классы от самого срочного к наименее срочному.
+var Priorities = []Priority{PriorityEmergency, PriorityCommand, PriorityTelemetry, PriorityBulk}
+
+// ParsePriority разбирает класс срочности; пустая строка — telemetry.
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityTelemetry, nil
+	}
+	for _, p := range Priorities {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown priority %q", s)
+}
+
+// Rank возвращает позицию класса в Priorities: 0 — самый срочный.
+// Пустой и неизвестный класс считается telemetry.
+func (p Priority) Rank() int {
+	for i, q := range Priorities {
+		if q == p {
+			return i
+		}
+	}
+	return PriorityTelemetry.Rank()
+}
+
+// Normalize заменяет пустой класс на telemetry.
+func (p Priority) Normalize() Priority {
+	return Priorities[p.Rank()]
+}
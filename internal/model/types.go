@@ -7,9 +7,24 @@ import (
 )
 
 type SendMessageRequest struct {
-	Sender    string `json:"sender"`
-	Message   string `json:"message"`
-	MessageID string `json:"messageId"`
+	Sender      string `json:"sender"`
+	Message     string `json:"message"`
+	MessageID   string `json:"messageId"`
+	CallbackURL string `json:"callbackUrl,omitempty"`
+	// RedLength — длина надёжного (red) префикса сообщения в байтах; остаток
+	// (green) отправляется один раз без повторов. Не задана — всё сообщение red.
+	RedLength *int `json:"redLength,omitempty"`
+	// Priority — класс срочности: emergency, command, telemetry (по умолчанию) или bulk
+	Priority Priority `json:"priority,omitempty"`
+	// Lifetime — время жизни сообщения от приёма (например "40m"), Deadline —
+	// абсолютный срок. Если заданы оба, действует более ранний
+	Lifetime string     `json:"lifetime,omitempty"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// Destination — узел назначения; не задан — ROUTE_DESTINATION.
+	// Destinations — несколько узлов или групп адресов: сообщение
+	// рассылается каждому получателю с отдельным отслеживанием ACK
+	Destination  string   `json:"destination,omitempty"`
+	Destinations []string `json:"destinations,omitempty"`
 }
 
 type Segment struct {
@@ -18,6 +33,35 @@ type Segment struct {
 	SegmentIndex  int    `json:"segmentIndex"`
 	TotalSegments int    `json:"totalSegments"`
 	Payload       string `json:"payload"`
+	// GreenSegments — число green-сегментов в конце сообщения
+	GreenSegments int `json:"greenSegments,omitempty"`
+	// Priority — класс срочности сообщения
+	Priority Priority `json:"priority,omitempty"`
+	// ExpiresAt — срок жизни сообщения в Unix-миллисекундах; 0 — бессрочно
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+	// Source и Destination — узлы отправителя и получателя при маршрутизации
+	// через ретрансляторы; пустой Destination — доставка без маршрутизации
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	// Custodian — узел, хранящий копию сегмента до передачи ответственности
+	// следующему узлу; пусто — передача ответственности не запрошена
+	Custodian string `json:"custodian,omitempty"`
+	Auth
+}
+
+// RedSegments возвращает число red-сегментов сообщения.
+func (s Segment) RedSegments() int {
+	return s.TotalSegments - s.GreenSegments
+}
+
+// Expired сообщает, истёк ли срок жизни сообщения к моменту now.
+func (s Segment) Expired(now time.Time) bool {
+	return s.ExpiresAt > 0 && now.UnixMilli() >= s.ExpiresAt
+}
+
+// IsGreen сообщает, относится ли сегмент к green-части, которая не повторяется.
+func (s Segment) IsGreen() bool {
+	return s.SegmentIndex >= s.RedSegments()
 }
 
 type Message struct {
@@ -25,17 +69,109 @@ type Message struct {
 	Content   string    `json:"content"`
 	Timestamp time.Time `json:"timestamp"`
 	HasError  bool      `json:"hasError"`
+	// MissingGreenSegments — green-сегменты, не дошедшие к моменту сборки;
+	// их данные в Content пропущены
+	MissingGreenSegments []int `json:"missingGreenSegments,omitempty"`
+	// Source — узел, с которого отправлено сообщение
+	Source string `json:"source,omitempty"`
 }
 
 type Ack struct {
 	MessageID            string `json:"messageId"`
 	LastConfirmedSegment int    `json:"lastConfirmedSegment"`
 	Final                bool   `json:"final"` // ← добавь это поле
+	// Destination — узел отправителя сообщения, которому адресован ACK
+	Destination string `json:"destination,omitempty"`
+	// Custody — сигнал передачи ответственности: узел сохранил сегменты
+	// Segments, и предыдущий хранитель может освободить их копии
+	Custody  bool  `json:"custody,omitempty"`
+	Segments []int `json:"segments,omitempty"`
+	// Failed — получатель отказался от сообщения (например, вытеснил его из
+	// буфера сборки): вместе с Final означает итоговую ошибку доставки
+	Failed bool `json:"failed,omitempty"`
+	Auth
+}
+
+// Auth — подпись кадра узлом Signer: HMAC содержимого кадра вместе с
+// моментом подписи SignedAt (Unix-наносекунды) на ключе этого узла
+type Auth struct {
+	Signer    string `json:"signer,omitempty"`
+	SignedAt  int64  `json:"signedAt,omitempty"`
+	Signature string `json:"signature,omitempty"`
 }
 
 type FinalAck struct {
 	MessageID string `json:"messageID"`
-	Status    string `json:"status"` // "success" или "error"
+	Status    string `json:"status"`
+	// Recipients — результат по каждому получателю сообщения, разосланного
+	// нескольким узлам
+	Recipients []RecipientStatus `json:"recipients,omitempty"`
+}
+
+type RecipientStatus struct {
+	Destination string `json:"destination"`
+	Status      string `json:"status"`
+}
+
+const (
+	StatusSuccess   = "success"
+	StatusError     = "error"
+	StatusCancelled = "cancelled"
+	StatusExpired   = "expired"
+	// StatusSent — копия целиком green: отправлена без подтверждения доставки
+	StatusSent = "sent"
+	// StatusPartial — получатели рассылки завершились с разными статусами
+	StatusPartial = "partial"
+)
+
+// Типы событий жизненного цикла сообщения для webhook-подписчиков
+const (
+	EventAccepted          = "accepted"
+	EventSegmentsPublished = "segments_published"
+	EventPartiallyAcked    = "partially_acked"
+	EventDelivered         = "delivered"
+	EventFailed            = "failed"
+	EventCancelled         = "cancelled"
+	EventExpired           = "expired"
+	// EventSent — сообщение целиком green: отправлено без подтверждения доставки
+	EventSent = "sent"
+)
+
+type DeliveryEvent struct {
+	Event                string    `json:"event"`
+	MessageID            string    `json:"messageId"`
+	Sender               string    `json:"sender"`
+	TotalSegments        int       `json:"totalSegments,omitempty"`
+	LastConfirmedSegment int       `json:"lastConfirmedSegment"`
+	Timestamp            time.Time `json:"timestamp"`
+}
+
+// Типы событий потока /events
+const (
+	StreamSegmentSent      = "segment_sent"
+	StreamSegmentReceived  = "segment_received"
+	StreamAckProcessed     = "ack_processed"
+	StreamResendTriggered  = "resend_triggered"
+	StreamMessageAssembled = "message_assembled"
+	StreamMessageFailed    = "message_failed"
+	StreamCustodyAccepted  = "custody_accepted"
+)
+
+type Event struct {
+	Type          string    `json:"type"`
+	MessageID     string    `json:"messageId"`
+	Sender        string    `json:"sender,omitempty"`
+	SegmentIndex  int       `json:"segmentIndex"`
+	TotalSegments int       `json:"totalSegments,omitempty"`
+	LastConfirmed int       `json:"lastConfirmed"`
+	Reason        string    `json:"reason,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+type Webhook struct {
+	Sender string `json:"sender"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
 }
 
 func ToReader(v interface{}) *bytes.Reader {
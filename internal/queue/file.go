@@ -0,0 +1,294 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"transport/internal/wire"
+)
+
+const (
+	logSuffix    = ".log"
+	offsetSuffix = ".offset"
+)
+
+type fileRecord struct {
+	Offset  int64             `json:"offset,omitempty"`
+	Key     []byte            `json:"key,omitempty"`
+	Value   []byte            `json:"value"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// fileStore хранит журнал каждого топика в <dir>/<topic>.log (JSON по
+// строке на сообщение, со смещением сообщения) и смещения групп в
+// <dir>/<topic>@<group>.offset; имена экранируются url.QueryEscape.
+type fileStore struct {
+	mu    sync.Mutex
+	dir   string
+	files map[string]*os.File
+}
+
+// NewFile создаёт встроенную файловую очередь в каталоге dir. Журналы и
+// зафиксированные смещения переживают перезапуск процесса.
+func NewFile(dir, segmentTopic string, format wire.Format) (*Memory, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	store := &fileStore{dir: dir, files: make(map[string]*os.File)}
+	q := NewMemory(segmentTopic, format)
+
+	if err := store.load(q); err != nil {
+		return nil, err
+	}
+	q.store = store
+
+	return q, nil
+}
+
+func (s *fileStore) load(q *Memory) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		switch {
+		case strings.HasSuffix(name, logSuffix):
+			topic, err := url.QueryUnescape(strings.TrimSuffix(name, logSuffix))
+			if err != nil {
+				continue
+			}
+			msgs, base, err := readLog(filepath.Join(s.dir, name), topic)
+			if err != nil {
+				return fmt.Errorf("read %s: %w", name, err)
+			}
+			t := q.topic(topic)
+			t.messages, t.base = msgs, base
+			log.Printf("[Queue] Restored %d message(s) of topic %s from offset %d", len(msgs), topic, base)
+
+		case strings.HasSuffix(name, offsetSuffix):
+			topic, group, ok := strings.Cut(strings.TrimSuffix(name, offsetSuffix), "@")
+			if !ok {
+				continue
+			}
+			topic, _ = url.QueryUnescape(topic)
+			group, _ = url.QueryUnescape(group)
+
+			data, err := os.ReadFile(filepath.Join(s.dir, name))
+			if err != nil {
+				return err
+			}
+			next, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+			if err != nil {
+				return fmt.Errorf("parse %s: %w", name, err)
+			}
+			q.topic(topic).committed[group] = next
+		}
+	}
+
+	return nil
+}
+
+// readLog читает журнал топика и возвращает его сообщения вместе со
+// смещением первого из них. Недописанный хвост (после аварийной остановки)
+// отрезается, чтобы следующие записи начинались с новой строки. Битая
+// строка в середине журнала заменяется пустым сообщением: смещения
+// следующих записей сохраняются, а consumer пропустит её как невалидную.
+func readLog(path, topic string) ([]Message, int64, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var msgs []Message
+	var valid int64
+	base := int64(-1)
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		valid += int64(len(line))
+
+		var rec fileRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			log.Printf("[Queue] Corrupt record %d in %s, skipping: %v", len(msgs), path, err)
+			msgs = append(msgs, Message{Topic: topic})
+			continue
+		}
+		// Смещение первой целой записи задаёт начало журнала после сжатия;
+		// в журналах без смещений записи нумеруются с нуля
+		if base < 0 {
+			base = max(rec.Offset-int64(len(msgs)), 0)
+		}
+		msgs = append(msgs, Message{
+			Topic:   topic,
+			Key:     rec.Key,
+			Value:   rec.Value,
+			Headers: rec.Headers,
+		})
+	}
+	base = max(base, 0)
+	for i := range msgs {
+		msgs[i].Offset = base + int64(i)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.Size() > valid {
+		log.Printf("[Queue] Truncating %d trailing byte(s) of %s", info.Size()-valid, path)
+		if err := f.Truncate(valid); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return msgs, base, nil
+}
+
+func (s *fileStore) logPath(topic string) string {
+	return filepath.Join(s.dir, url.QueryEscape(topic)+logSuffix)
+}
+
+// Append дописывает сообщения в журнал топика. При ошибке записи журнал
+// обрезается до прежнего размера, чтобы недописанная строка не оказалась
+// перед следующими записями.
+func (s *fileStore) Append(topic string, msgs []Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[topic]
+	if !ok {
+		var err error
+		f, err = os.OpenFile(s.logPath(topic), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		s.files[topic] = f
+	}
+
+	buf, err := encodeRecords(msgs)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		if terr := f.Truncate(info.Size()); terr != nil {
+			log.Printf("[Queue] Failed to roll back partial write to %s: %v", f.Name(), terr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Compact атомарно заменяет журнал топика записями msgs.
+func (s *fileStore) Compact(topic string, msgs []Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buf, err := encodeRecords(msgs)
+	if err != nil {
+		return err
+	}
+
+	err = replaceFile(s.logPath(topic), buf)
+
+	// Открытый дескриптор может указывать на прежний файл; следующий Append
+	// откроет журнал заново
+	if old, ok := s.files[topic]; ok {
+		old.Close()
+		delete(s.files, topic)
+	}
+	return err
+}
+
+func encodeRecords(msgs []Message) ([]byte, error) {
+	var buf []byte
+	for _, msg := range msgs {
+		line, err := json.Marshal(fileRecord{Offset: msg.Offset, Key: msg.Key, Value: msg.Value, Headers: msg.Headers})
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+	return buf, nil
+}
+
+// Commit сохраняет смещение группы. Файл смещения заменяется атомарно и
+// сбрасывается на диск до возврата: иначе после сбоя питания группа
+// перечитала бы уже обработанные сообщения или потеряла бы смещение.
+func (s *fileStore) Commit(topic, groupID string, next int64) error {
+	name := url.QueryEscape(topic) + "@" + url.QueryEscape(groupID) + offsetSuffix
+	return replaceFile(filepath.Join(s.dir, name), []byte(strconv.FormatInt(next, 10)))
+}
+
+// replaceFile атомарно заменяет файл path содержимым data: пишет его во
+// временный файл, сбрасывает на диск и переименовывает, после чего
+// сбрасывает каталог, чтобы переименование пережило сбой питания.
+func replaceFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func (s *fileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for topic, f := range s.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.files, topic)
+	}
+	return firstErr
+}
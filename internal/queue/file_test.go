@@ -0,0 +1,133 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"transport/internal/wire"
+)
+
+func openFile(t *testing.T, dir string) *Memory {
+	t.Helper()
+	q, err := NewFile(dir, "segments", wire.FormatBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func TestFileCommitResume(t *testing.T) {
+	dir := t.TempDir()
+	q := openFile(t, dir)
+	writeN(t, q, "t", 3)
+	sub := q.Subscribe("t", "g")
+	fetch(t, sub)
+	if err := sub.Commit(context.Background(), fetch(t, sub)); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, "t@g"+offsetSuffix))
+	if err != nil || string(data) != "2" {
+		t.Fatalf("offset file = %q (%v), want 2", data, err)
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(tmp) > 0 {
+		t.Fatalf("temporary files left: %v", tmp)
+	}
+
+	q = openFile(t, dir)
+	msg := fetch(t, q.Subscribe("t", "g"))
+	if msg.Offset != 2 || string(msg.Value) != "2" {
+		t.Fatalf("resumed at %d %q, want offset 2", msg.Offset, msg.Value)
+	}
+}
+
+// Недописанная последняя строка отрезается, и следующая запись начинается
+// с новой строки
+func TestFileTornTail(t *testing.T) {
+	dir := t.TempDir()
+	q := openFile(t, dir)
+	writeN(t, q, "t", 2)
+	q.Close()
+
+	path := filepath.Join(dir, "t"+logSuffix)
+	whole, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, append(append([]byte(nil), whole...), `{"offset":2,"val`...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	q = openFile(t, dir)
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, whole) {
+		t.Fatalf("torn tail not truncated: %q", got)
+	}
+	writeN(t, q, "t", 1)
+	q.Close()
+
+	q = openFile(t, dir)
+	sub := q.Subscribe("t", "g")
+	for want := int64(0); want < 3; want++ {
+		if msg := fetch(t, sub); msg.Offset != want {
+			t.Fatalf("offset = %d, want %d", msg.Offset, want)
+		}
+	}
+}
+
+// Битая строка в середине журнала становится пустым сообщением, смещения
+// следующих записей не сдвигаются
+func TestFileCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	q := openFile(t, dir)
+	writeN(t, q, "t", 3)
+	q.Close()
+
+	path := filepath.Join(dir, "t"+logSuffix)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	lines[1] = []byte("garbage\n")
+	if err := os.WriteFile(path, bytes.Join(lines, nil), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	q = openFile(t, dir)
+	sub := q.Subscribe("t", "g")
+	for i, want := range []string{"0", "", "2"} {
+		msg := fetch(t, sub)
+		if msg.Offset != int64(i) || string(msg.Value) != want {
+			t.Fatalf("message %d = offset %d %q, want %q", i, msg.Offset, msg.Value, want)
+		}
+	}
+}
+
+// Сжатый журнал после перезапуска сохраняет смещения
+func TestFileCompaction(t *testing.T) {
+	dir := t.TempDir()
+	q := openFile(t, dir)
+	n := compactThreshold + 5
+	writeN(t, q, "t", n)
+	if err := q.Subscribe("t", "g").Commit(context.Background(), Message{Offset: int64(n - 1)}); err != nil {
+		t.Fatal(err)
+	}
+	writeN(t, q, "t", 1)
+	q.Close()
+
+	q = openFile(t, dir)
+	if base := q.topics["t"].base; base != int64(n-1) {
+		t.Fatalf("restored base = %d, want %d", base, n-1)
+	}
+	if msg := fetch(t, q.Subscribe("t", "g")); msg.Offset != int64(n) {
+		t.Fatalf("resumed at offset %d, want %d", msg.Offset, n)
+	}
+	writeN(t, q, "t", 1)
+	if msg := fetch(t, q.Subscribe("t", "new")); msg.Offset != int64(n-1) {
+		t.Fatalf("new group starts at %d, want %d", msg.Offset, n-1)
+	}
+}
@@ -0,0 +1,291 @@
+package queue
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"transport/internal/metrics"
+	"transport/internal/model"
+	"transport/internal/wire"
+)
+
+type memoryTopic struct {
+	// messages — журнал начиная со смещения base; более ранние сообщения
+	// зафиксированы всеми группами и отброшены
+	messages []Message
+	base     int64
+	// committed — следующее смещение к чтению для каждой группы
+	committed map[string]int64
+	// notify закрывается и пересоздаётся при каждой записи
+	notify chan struct{}
+}
+
+func newMemoryTopic() *memoryTopic {
+	return &memoryTopic{
+		committed: make(map[string]int64),
+		notify:    make(chan struct{}),
+	}
+}
+
+// compactThreshold — сколько сообщений, зафиксированных всеми группами,
+// накапливается в начале журнала топика, прежде чем они отбрасываются.
+const compactThreshold = 1024
+
+// logStore сохраняет журнал топиков и смещения групп; используется файловой очередью.
+type logStore interface {
+	Append(topic string, msgs []Message) error
+	Commit(topic, groupID string, next int64) error
+	// Compact переписывает журнал топика, оставляя только сообщения msgs
+	Compact(topic string, msgs []Message) error
+	Close() error
+}
+
+// Memory — очередь сегментов внутри процесса. Каждый топик — одна партиция
+// с журналом в памяти; ожидающие Fetch пробуждаются через канал уведомлений.
+// Предназначена для разработки и CI без брокера. Начало журнала, которое
+// прочитали и зафиксировали все группы топика, отбрасывается.
+type Memory struct {
+	mu           sync.Mutex
+	topics       map[string]*memoryTopic
+	segmentTopic string
+	format       wire.Format
+	store        logStore
+	closed       bool
+}
+
+func NewMemory(segmentTopic string, format wire.Format) *Memory {
+	return &Memory{
+		topics:       make(map[string]*memoryTopic),
+		segmentTopic: segmentTopic,
+		format:       format,
+	}
+}
+
+// topic вызывается под m.mu.
+func (m *Memory) topic(name string) *memoryTopic {
+	t, ok := m.topics[name]
+	if !ok {
+		t = newMemoryTopic()
+		m.topics[name] = t
+	}
+	return t
+}
+
+func (m *Memory) Write(ctx context.Context, msgs ...Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	byTopic := make(map[string][]Message)
+	var order []string
+	for _, msg := range msgs {
+		if _, ok := byTopic[msg.Topic]; !ok {
+			order = append(order, msg.Topic)
+		}
+		byTopic[msg.Topic] = append(byTopic[msg.Topic], msg)
+	}
+
+	for _, name := range order {
+		t := m.topic(name)
+		batch := byTopic[name]
+		for i := range batch {
+			batch[i].Partition = 0
+			batch[i].Offset = t.base + int64(len(t.messages)+i)
+		}
+
+		if m.store != nil {
+			if err := m.store.Append(name, batch); err != nil {
+				return err
+			}
+		}
+
+		t.messages = append(t.messages, batch...)
+		close(t.notify)
+		t.notify = make(chan struct{})
+	}
+
+	return nil
+}
+
+// SendSegments записывает сегменты каждого сообщения одной операцией под
+// блокировкой, поэтому сообщение публикуется целиком или не публикуется вовсе.
+// Сообщения публикуются в топики своих классов, срочные — первыми.
+func (m *Memory) SendSegments(ctx context.Context, segments []model.Segment) error {
+	order, byMessage := GroupByMessage(segments)
+	SortByPriority(order, byMessage)
+	failed := make(map[string]error)
+
+	for _, id := range order {
+		batch := byMessage[id]
+		sender := batch[0].Sender
+
+		topic := PriorityTopic(m.segmentTopic, batch[0].Priority)
+		msgs, err := SegmentMessages(topic, m.format, batch)
+		if err == nil {
+			err = m.Write(ctx, msgs...)
+		}
+
+		outcome := metrics.OutcomeOK
+		if err != nil {
+			outcome = metrics.OutcomeError
+			failed[id] = err
+			log.Printf("[Queue] Failed to publish %d segment(s) of message %s: %v", len(batch), id, err)
+		} else {
+			log.Printf("[Queue] Published %d segment(s) of message %s to %s", len(batch), id, topic)
+		}
+		metrics.SegmentsProduced.WithLabelValues(metrics.Sender(sender), outcome).Add(float64(len(batch)))
+	}
+
+	if len(failed) > 0 {
+		return &BatchError{Messages: failed}
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(topic, groupID string) Subscription {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Группа без зафиксированного смещения читает с начала журнала и
+	// удерживает его от сжатия, пока не зафиксирует прочитанное
+	t := m.topic(topic)
+	if _, ok := t.committed[groupID]; !ok {
+		t.committed[groupID] = t.base
+	}
+
+	return &memorySubscription{
+		queue:   m,
+		topic:   topic,
+		groupID: groupID,
+		pos:     t.committed[groupID],
+	}
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	for _, t := range m.topics {
+		close(t.notify)
+	}
+
+	if m.store != nil {
+		return m.store.Close()
+	}
+	return nil
+}
+
+type memorySubscription struct {
+	queue   *Memory
+	topic   string
+	groupID string
+	pos     int64
+}
+
+func (s *memorySubscription) Fetch(ctx context.Context) (Message, error) {
+	for {
+		s.queue.mu.Lock()
+		if s.queue.closed {
+			s.queue.mu.Unlock()
+			return Message{}, ErrClosed
+		}
+
+		t := s.queue.topic(s.topic)
+		s.pos = max(s.pos, t.base)
+		if i := s.pos - t.base; i < int64(len(t.messages)) {
+			msg := t.messages[i]
+			s.pos++
+			s.queue.mu.Unlock()
+			return msg, nil
+		}
+		wait := t.notify
+		s.queue.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+func (s *memorySubscription) Commit(ctx context.Context, msg Message) error {
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+
+	t := s.queue.topic(s.topic)
+	next := msg.Offset + 1
+	if next <= t.committed[s.groupID] {
+		return nil
+	}
+
+	if s.queue.store != nil {
+		if err := s.queue.store.Commit(s.topic, s.groupID, next); err != nil {
+			return err
+		}
+	}
+	t.committed[s.groupID] = next
+	s.queue.compact(s.topic, t)
+	return nil
+}
+
+// compact отбрасывает начало журнала топика, зафиксированное всеми
+// группами, когда в нём набирается compactThreshold сообщений. Вызывается
+// под m.mu.
+func (m *Memory) compact(name string, t *memoryTopic) {
+	low := int64(math.MaxInt64)
+	for _, next := range t.committed {
+		low = min(low, next)
+	}
+	// Последнее сообщение остаётся в журнале: по его смещению файловая
+	// очередь после перезапуска продолжает нумерацию
+	drop := min(low-t.base, int64(len(t.messages))-1)
+	if drop < compactThreshold {
+		return
+	}
+
+	rest := t.messages[drop:]
+	if m.store != nil {
+		if err := m.store.Compact(name, rest); err != nil {
+			log.Printf("[Queue] Failed to compact topic %s: %v", name, err)
+			return
+		}
+	}
+	t.messages = append([]Message(nil), rest...)
+	t.base += drop
+	log.Printf("[Queue] Compacted %d committed message(s) of topic %s", drop, name)
+}
+
+func (s *memorySubscription) Lag() int64 {
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+
+	t := s.queue.topic(s.topic)
+	return t.base + int64(len(t.messages)) - t.committed[s.groupID]
+}
+
+func (s *memorySubscription) Close() error {
+	return nil
+}
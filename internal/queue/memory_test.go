@@ -0,0 +1,155 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"transport/internal/model"
+	"transport/internal/wire"
+)
+
+func writeN(t *testing.T, q *Memory, topic string, n int) {
+	t.Helper()
+	msgs := make([]Message, n)
+	for i := range msgs {
+		msgs[i] = Message{Topic: topic, Value: []byte(fmt.Sprint(i))}
+	}
+	if err := q.Write(context.Background(), msgs...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fetch(t *testing.T, sub Subscription) Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, err := sub.Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestMemoryCommitResume(t *testing.T) {
+	q := NewMemory("segments", wire.FormatBinary)
+	writeN(t, q, "t", 3)
+
+	sub := q.Subscribe("t", "g")
+	fetch(t, sub)
+	second := fetch(t, sub)
+	if err := sub.Commit(context.Background(), second); err != nil {
+		t.Fatal(err)
+	}
+	if lag := sub.Lag(); lag != 1 {
+		t.Fatalf("lag = %d, want 1", lag)
+	}
+
+	// Новая подписка группы продолжает с зафиксированного смещения, а
+	// фиксация более раннего сообщения смещение не откатывает
+	sub = q.Subscribe("t", "g")
+	if msg := fetch(t, sub); msg.Offset != 2 {
+		t.Fatalf("resumed at offset %d, want 2", msg.Offset)
+	}
+	if err := sub.Commit(context.Background(), Message{Offset: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if lag := sub.Lag(); lag != 1 {
+		t.Fatalf("lag after stale commit = %d, want 1", lag)
+	}
+
+	// Другая группа читает топик с начала
+	if msg := fetch(t, q.Subscribe("t", "other")); msg.Offset != 0 {
+		t.Fatalf("new group starts at offset %d, want 0", msg.Offset)
+	}
+}
+
+func TestMemoryCompaction(t *testing.T) {
+	q := NewMemory("segments", wire.FormatBinary)
+	slow := q.Subscribe("t", "slow")
+	fast := q.Subscribe("t", "fast")
+	n := compactThreshold + 10
+	writeN(t, q, "t", n)
+
+	last := Message{Offset: int64(n - 1)}
+	if err := fast.Commit(context.Background(), last); err != nil {
+		t.Fatal(err)
+	}
+	// Журнал держит отстающая группа
+	if base := q.topics["t"].base; base != 0 {
+		t.Fatalf("compacted to %d while a group lags", base)
+	}
+
+	if err := slow.Commit(context.Background(), last); err != nil {
+		t.Fatal(err)
+	}
+	topic := q.topics["t"]
+	if topic.base != int64(n-1) || len(topic.messages) != 1 {
+		t.Fatalf("after compaction base = %d, %d message(s); want %d, 1", topic.base, len(topic.messages), n-1)
+	}
+
+	// Нумерация продолжается, а новая группа читает с начала оставшегося журнала
+	writeN(t, q, "t", 1)
+	late := q.Subscribe("t", "late")
+	if msg := fetch(t, late); msg.Offset != int64(n-1) {
+		t.Fatalf("new group starts at offset %d, want %d", msg.Offset, n-1)
+	}
+	if msg := fetch(t, late); msg.Offset != int64(n) {
+		t.Fatalf("next offset = %d, want %d", msg.Offset, n)
+	}
+}
+
+// failingStore отказывает в записи в топик failTopic.
+type failingStore struct {
+	failTopic string
+}
+
+var errStore = errors.New("disk full")
+
+func (s failingStore) Append(topic string, msgs []Message) error {
+	if topic == s.failTopic {
+		return errStore
+	}
+	return nil
+}
+
+func (failingStore) Commit(topic, groupID string, next int64) error { return nil }
+func (failingStore) Compact(topic string, msgs []Message) error     { return nil }
+func (failingStore) Close() error                                   { return nil }
+
+// Сообщение, которое не удалось записать, попадает в BatchError; сегменты
+// остальных сообщений записаны
+func TestSendSegmentsBatchError(t *testing.T) {
+	q := NewMemory("segments", wire.FormatBinary)
+	q.store = failingStore{failTopic: PriorityTopic("segments", model.PriorityCommand)}
+
+	segments := []model.Segment{
+		{MessageID: "tm", TotalSegments: 2, SegmentIndex: 0, Priority: model.PriorityTelemetry},
+		{MessageID: "cmd", TotalSegments: 1, SegmentIndex: 0, Priority: model.PriorityCommand},
+		{MessageID: "tm", TotalSegments: 2, SegmentIndex: 1, Priority: model.PriorityTelemetry},
+	}
+	err := q.SendSegments(context.Background(), segments)
+
+	var batch *BatchError
+	if !errors.As(err, &batch) {
+		t.Fatalf("error = %v, want *BatchError", err)
+	}
+	if len(batch.Messages) != 1 || !errors.Is(batch.Messages["cmd"], errStore) {
+		t.Fatalf("failed messages = %v, want only cmd", batch.Messages)
+	}
+	if want := "failed to publish 1 message(s): cmd: disk full"; batch.Error() != want {
+		t.Fatalf("Error() = %q, want %q", batch.Error(), want)
+	}
+
+	sub := q.Subscribe("segments", "g")
+	for i := 0; i < 2; i++ {
+		seg, err := wire.DecodeSegment(fetch(t, sub).Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seg.MessageID != "tm" || seg.SegmentIndex != i {
+			t.Fatalf("segment %d = %s/%d, want tm/%d", i, seg.MessageID, seg.SegmentIndex, i)
+		}
+	}
+}
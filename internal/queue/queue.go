@@ -0,0 +1,132 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"transport/internal/model"
+	"transport/internal/wire"
+)
+
+// ErrClosed возвращается Fetch и Write после закрытия очереди.
+var ErrClosed = errors.New("queue closed")
+
+// Message — запись очереди. Partition и Offset заполняются реализацией при
+// чтении и используются для фиксации.
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+}
+
+// Publisher публикует сегменты в основной топик сегментов.
+type Publisher interface {
+	// SendSegments публикует сегменты, группируя их по messageId; при
+	// частичном сбое возвращает *BatchError.
+	SendSegments(ctx context.Context, segments []model.Segment) error
+}
+
+// Subscription читает топик от имени группы. Commit фиксирует сообщение и
+// все предыдущие в его партиции.
+type Subscription interface {
+	Fetch(ctx context.Context) (Message, error)
+	Commit(ctx context.Context, msg Message) error
+	Lag() int64
+	Close() error
+}
+
+// SegmentQueue — транспорт сегментов между приёмом /sendMessage и
+// пересылкой в канал: Kafka, in-memory или файловая очередь.
+type SegmentQueue interface {
+	Publisher
+	// Write записывает произвольные сообщения в топики, указанные в Message.Topic.
+	Write(ctx context.Context, msgs ...Message) error
+	Subscribe(topic, groupID string) Subscription
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// BatchError сообщает, какие сообщения не удалось опубликовать в SendSegments.
+// Ключ — messageId, значение — причина; сегменты остальных сообщений записаны.
+type BatchError struct {
+	Messages map[string]error
+}
+
+func (e *BatchError) Error() string {
+	ids := make([]string, 0, len(e.Messages))
+	for id := range e.Messages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	msg := fmt.Sprintf("failed to publish %d message(s):", len(ids))
+	for i, id := range ids {
+		if i > 0 {
+			msg += ";"
+		}
+		msg += fmt.Sprintf(" %s: %v", id, e.Messages[id])
+	}
+	return msg
+}
+
+// GroupByMessage разбивает сегменты по messageId, сохраняя порядок первого появления.
+func GroupByMessage(segments []model.Segment) (order []string, byMessage map[string][]model.Segment) {
+	byMessage = make(map[string][]model.Segment)
+	for _, seg := range segments {
+		if _, ok := byMessage[seg.MessageID]; !ok {
+			order = append(order, seg.MessageID)
+		}
+		byMessage[seg.MessageID] = append(byMessage[seg.MessageID], seg)
+	}
+	return order, byMessage
+}
+
+// PriorityTopic возвращает топик класса p для базового топика base.
+// Telemetry использует сам base, поэтому сегменты без класса и записанные
+// до появления классов читаются из прежнего топика.
+func PriorityTopic(base string, p model.Priority) string {
+	p = p.Normalize()
+	if p == model.PriorityTelemetry {
+		return base
+	}
+	return base + "." + string(p)
+}
+
+// PriorityTopics возвращает топики всех классов от самого срочного.
+func PriorityTopics(base string) []string {
+	topics := make([]string, 0, len(model.Priorities))
+	for _, p := range model.Priorities {
+		topics = append(topics, PriorityTopic(base, p))
+	}
+	return topics
+}
+
+// SortByPriority упорядочивает messageId из GroupByMessage так, чтобы
+// сообщения более срочных классов публиковались первыми; внутри класса
+// порядок сохраняется.
+func SortByPriority(order []string, byMessage map[string][]model.Segment) {
+	sort.SliceStable(order, func(i, j int) bool {
+		return byMessage[order[i]][0].Priority.Rank() < byMessage[order[j]][0].Priority.Rank()
+	})
+}
+
+// SegmentMessages кодирует сегменты в формате format в сообщения топика с ключом messageId.
+func SegmentMessages(topic string, format wire.Format, segments []model.Segment) ([]Message, error) {
+	msgs := make([]Message, 0, len(segments))
+	for _, seg := range segments {
+		data, err := wire.EncodeSegment(format, seg)
+		if err != nil {
+			return nil, fmt.Errorf("encode segment %d of message %s: %w", seg.SegmentIndex, seg.MessageID, err)
+		}
+		msgs = append(msgs, Message{
+			Topic: topic,
+			Key:   []byte(seg.MessageID),
+			Value: data,
+		})
+	}
+	return msgs, nil
+}
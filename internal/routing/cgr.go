@@ -0,0 +1,91 @@
+package routing
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Route — маршрут до узла назначения: цепочка сеансов и расчётное время прибытия.
+type Route struct {
+	Hops    []Contact
+	Arrival time.Time
+}
+
+// NextHop возвращает соседа, которому передаётся первый участок маршрута.
+func (r Route) NextHop() string {
+	return r.Hops[0].To
+}
+
+// FindRoute ищет маршрут от src к dst с самым ранним прибытием для данных,
+// готовых к отправке в момент now (алгоритм Дейкстры по сеансам связи).
+// Участок можно пройти, если сеанс ещё не закончился к моменту прибытия
+// данных на его начальный узел; отправка — не раньше начала сеанса,
+// прибытие — через световое время. Время передачи size байт учитывается
+// на скорости каждого сеанса. false — маршрута нет.
+func (g *Graph) FindRoute(src, dst string, now time.Time, size int) (Route, bool) {
+	arrival := map[string]time.Time{src: now}
+	via := make(map[string]Contact)
+	done := make(map[string]bool)
+
+	pq := &arrivalQueue{{node: src, at: now}}
+	for pq.Len() > 0 {
+		cur := heap.Pop(pq).(arrivalItem)
+		if done[cur.node] {
+			continue
+		}
+		done[cur.node] = true
+		if cur.node == dst {
+			break
+		}
+
+		for _, c := range g.Contacts {
+			if c.From != cur.node || done[c.To] {
+				continue
+			}
+			depart := cur.at
+			if depart.Before(c.Start) {
+				depart = c.Start
+			}
+			sent := depart.Add(c.TransmitTime(size))
+			if sent.After(c.End) {
+				continue
+			}
+			at := sent.Add(c.LightTime)
+			if best, ok := arrival[c.To]; ok && !at.Before(best) {
+				continue
+			}
+			arrival[c.To] = at
+			via[c.To] = c
+			heap.Push(pq, arrivalItem{node: c.To, at: at})
+		}
+	}
+
+	if !done[dst] || src == dst {
+		return Route{}, false
+	}
+
+	var hops []Contact
+	for node := dst; node != src; node = via[node].From {
+		hops = append([]Contact{via[node]}, hops...)
+	}
+	return Route{Hops: hops, Arrival: arrival[dst]}, true
+}
+
+type arrivalItem struct {
+	node string
+	at   time.Time
+}
+
+// arrivalQueue — очередь узлов по времени прибытия для container/heap.
+type arrivalQueue []arrivalItem
+
+func (q arrivalQueue) Len() int           { return len(q) }
+func (q arrivalQueue) Less(i, j int) bool { return q[i].at.Before(q[j].at) }
+func (q arrivalQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *arrivalQueue) Push(x any)        { *q = append(*q, x.(arrivalItem)) }
+func (q *arrivalQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
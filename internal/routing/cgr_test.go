@@ -0,0 +1,89 @@
+package routing
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"transport/internal/contact"
+)
+
+var t0 = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func window(from, to string, start, end time.Duration, rate int64, light time.Duration) Contact {
+	return Contact{From: from, To: to, Window: contact.Window{
+		Start:     t0.Add(start),
+		End:       t0.Add(end),
+		DataRate:  rate,
+		LightTime: light,
+	}}
+}
+
+func testGraph(contacts ...Contact) *Graph {
+	g := &Graph{Nodes: map[string]Node{}, Contacts: contacts}
+	for _, id := range []string{"earth", "mro", "tgo", "mars", "europa"} {
+		g.Nodes[id] = Node{ID: id}
+	}
+	return g
+}
+
+// path возвращает узлы маршрута после источника.
+func path(r Route) []string {
+	var nodes []string
+	for _, c := range r.Hops {
+		nodes = append(nodes, c.To)
+	}
+	return nodes
+}
+
+func TestFindRoute(t *testing.T) {
+	direct := window("earth", "mars", 10*time.Hour, 11*time.Hour, 0, 12*time.Minute)
+	toMRO := window("earth", "mro", 0, time.Hour, 8000, time.Second)
+	fromMRO := window("mro", "mars", time.Hour, 2*time.Hour, 0, time.Second)
+	toTGO := window("earth", "tgo", 3*time.Hour, 4*time.Hour, 0, time.Second)
+	fromTGO := window("tgo", "mars", 5*time.Hour, 6*time.Hour, 0, time.Second)
+
+	tests := []struct {
+		name     string
+		graph    *Graph
+		src, dst string
+		now      time.Duration
+		size     int
+		want     []string
+		arrival  time.Duration
+	}{
+		{"direct only", testGraph(direct), "earth", "mars", 0, 100,
+			[]string{"mars"}, 10*time.Hour + 12*time.Minute},
+		{"relay arrives earlier", testGraph(direct, toMRO, fromMRO), "earth", "mars", 0, 100,
+			[]string{"mro", "mars"}, time.Hour + time.Second},
+		{"closed relay contact falls back to direct", testGraph(direct, toMRO, fromMRO), "earth", "mars", 90 * time.Minute, 100,
+			[]string{"mars"}, 10*time.Hour + 12*time.Minute},
+		{"closed relay contact takes another relay", testGraph(direct, toMRO, fromMRO, toTGO, fromTGO), "earth", "mars", 90 * time.Minute, 100,
+			[]string{"tgo", "mars"}, 5*time.Hour + time.Second},
+		// 4 МБ на 8 кбит/с передаются дольше часового окна к MRO
+		{"relay window too short for size", testGraph(direct, toMRO, fromMRO), "earth", "mars", 0, 4 << 20,
+			[]string{"mars"}, 10*time.Hour + 12*time.Minute},
+		{"no route", testGraph(direct, toMRO, fromMRO), "earth", "europa", 0, 100, nil, 0},
+		{"contacts are directed", testGraph(direct), "mars", "earth", 0, 100, nil, 0},
+		{"source is destination", testGraph(direct), "earth", "earth", 0, 100, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, ok := tt.graph.FindRoute(tt.src, tt.dst, t0.Add(tt.now), tt.size)
+			if ok != (tt.want != nil) {
+				t.Fatalf("FindRoute ok = %v, want %v (route %v)", ok, tt.want != nil, path(route))
+			}
+			if !ok {
+				return
+			}
+			if got := path(route); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("route = %v, want %v", got, tt.want)
+			}
+			if want := t0.Add(tt.arrival); !route.Arrival.Equal(want) {
+				t.Fatalf("arrival = %s, want %s", route.Arrival, want)
+			}
+			if route.NextHop() != tt.want[0] {
+				t.Fatalf("NextHop = %s, want %s", route.NextHop(), tt.want[0])
+			}
+		})
+	}
+}
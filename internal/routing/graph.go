@@ -0,0 +1,99 @@
+// Package routing прокладывает маршруты сегментов и ACK через
+// промежуточные узлы (орбитальные ретрансляторы) по графу контактов —
+// Contact Graph Routing: из всех цепочек сеансов связи выбирается та,
+// что доставит данные к узлу назначения раньше всех.
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"transport/internal/contact"
+)
+
+// Node — узел сети. URL — адрес канала, через который этот экземпляр
+// транспорта передаёт данные соседу; для несоседних узлов не нужен.
+// Framing — кадрирование канала к соседу (wire, bundle или ccsds); пусто —
+// CHANNEL_FRAMING.
+type Node struct {
+	ID      string `json:"id"`
+	URL     string `json:"url,omitempty"`
+	Framing string `json:"framing,omitempty"`
+}
+
+// Contact — сеанс связи от узла From к узлу To.
+type Contact struct {
+	From string
+	To   string
+	contact.Window
+}
+
+type contactJSON struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	contact.WindowJSON
+}
+
+// Graph — узлы и сеансы связи между ними.
+type Graph struct {
+	Nodes    map[string]Node
+	Contacts []Contact
+}
+
+// LoadGraph читает граф контактов из JSON-файла вида
+//
+//	{"nodes": [{"id": "mro", "url": "http://channel-mro:8081", "framing": "ccsds"}, ...],
+//	 "contacts": [{"from": "earth", "to": "mro", "start": "...", "end": "...",
+//	               "dataRate": 2000000, "lightTime": "12m"}, ...]}
+func LoadGraph(path string) (*Graph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Nodes    []Node        `json:"nodes"`
+		Contacts []contactJSON `json:"contacts"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	g := &Graph{Nodes: make(map[string]Node, len(raw.Nodes))}
+	for _, n := range raw.Nodes {
+		if n.ID == "" {
+			return nil, fmt.Errorf("parse %s: node without id", path)
+		}
+		switch n.Framing {
+		case "", "wire", "bundle", "ccsds":
+		default:
+			return nil, fmt.Errorf("parse %s: node %s: unknown framing %q (expected wire, bundle or ccsds)", path, n.ID, n.Framing)
+		}
+		g.Nodes[n.ID] = n
+	}
+	for i, c := range raw.Contacts {
+		if _, ok := g.Nodes[c.From]; !ok {
+			return nil, fmt.Errorf("parse %s: contact %d: unknown node %q", path, i, c.From)
+		}
+		if _, ok := g.Nodes[c.To]; !ok {
+			return nil, fmt.Errorf("parse %s: contact %d: unknown node %q", path, i, c.To)
+		}
+		w, err := c.WindowJSON.Window()
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: contact %d: %w", path, i, err)
+		}
+		g.Contacts = append(g.Contacts, Contact{From: c.From, To: c.To, Window: w})
+	}
+	return g, nil
+}
+
+// outgoing возвращает окна сеансов от from к to.
+func (g *Graph) outgoing(from, to string) []contact.Window {
+	var windows []contact.Window
+	for _, c := range g.Contacts {
+		if c.From == from && c.To == to {
+			windows = append(windows, c.Window)
+		}
+	}
+	return windows
+}
@@ -0,0 +1,168 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"transport/internal/contact"
+)
+
+// ErrNoRoute — к узлу назначения нет маршрута по текущему графу контактов.
+var ErrNoRoute = errors.New("no route")
+
+// Router выбирает следующий узел для данных, адресованных другим узлам, и
+// выдаёт время отправки по сеансам связи с этим соседом. Без графа
+// маршрутизация выключена и данные идут напрямую в ChannelURL.
+type Router struct {
+	nodeID string
+	path   string
+
+	mu    sync.RWMutex
+	graph *Graph
+	links map[string]*link
+}
+
+// link — сеансы связи с соседом и очередь отправки по ним. removed
+// закрывается, когда сосед пропадает из графа.
+type link struct {
+	plan    *contact.Plan
+	sched   *contact.Scheduler
+	removed chan struct{}
+}
+
+func NewRouter(nodeID, graphPath string) *Router {
+	return &Router{
+		nodeID: nodeID,
+		path:   graphPath,
+		graph:  &Graph{Nodes: map[string]Node{}},
+		links:  make(map[string]*link),
+	}
+}
+
+// Enabled сообщает, задан ли граф контактов.
+func (r *Router) Enabled() bool {
+	return r.path != ""
+}
+
+// NodeID возвращает идентификатор этого узла.
+func (r *Router) NodeID() string {
+	return r.nodeID
+}
+
+// IsLocal сообщает, адресованы ли данные этому узлу. Пустой адрес —
+// данные без маршрутизации, они тоже обрабатываются локально.
+func (r *Router) IsLocal(dst string) bool {
+	return !r.Enabled() || dst == "" || dst == r.nodeID
+}
+
+// Reload перечитывает граф контактов. Сеансы с соседями обновляются на
+// месте, поэтому ожидающие отправки сегменты видят новое расписание.
+// Соседи, пропавшие из графа, забываются, а ожидающие сеанса с ними
+// сегменты получают ошибку и ищут новый маршрут.
+func (r *Router) Reload() error {
+	if !r.Enabled() {
+		return nil
+	}
+
+	g, err := LoadGraph(r.path)
+	if err != nil {
+		return err
+	}
+	if _, ok := g.Nodes[r.nodeID]; !ok {
+		return fmt.Errorf("node %q is not in contact graph %s", r.nodeID, r.path)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.graph = g
+	for id := range g.Nodes {
+		if id == r.nodeID {
+			continue
+		}
+		windows := g.outgoing(r.nodeID, id)
+		if l, ok := r.links[id]; ok {
+			l.plan.Set(windows)
+			continue
+		}
+		plan := contact.NewStaticPlan(windows)
+		r.links[id] = &link{plan: plan, sched: contact.NewScheduler(plan), removed: make(chan struct{})}
+	}
+	for id, l := range r.links {
+		if _, ok := g.Nodes[id]; !ok || id == r.nodeID {
+			close(l.removed)
+			delete(r.links, id)
+			log.Printf("[Routing] Node %s removed from contact graph", id)
+		}
+	}
+
+	log.Printf("[Routing] Loaded contact graph from %s: %d node(s), %d contact(s)", r.path, len(g.Nodes), len(g.Contacts))
+	return nil
+}
+
+// Run периодически перечитывает граф, пока не будет отменён ctx.
+func (r *Router) Run(ctx context.Context, interval time.Duration) {
+	if !r.Enabled() || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Reload(); err != nil {
+				log.Printf("[Routing] Failed to reload contact graph: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Hop — следующий узел маршрута.
+type Hop struct {
+	Node  Node
+	Route Route
+	link  *link
+}
+
+// Wait блокируется до сеанса связи с соседом, в который помещается size
+// байт. Если сосед за время ожидания пропал из графа, возвращает ErrNoRoute.
+func (h Hop) Wait(ctx context.Context, size int) error {
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-h.link.removed:
+			cancel()
+		case <-waitCtx.Done():
+		}
+	}()
+
+	err := h.link.sched.Wait(waitCtx, size)
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("%w: neighbour %s removed from contact graph", ErrNoRoute, h.Node.ID)
+	}
+	return err
+}
+
+// NextHop выбирает соседа для данных размером size байт, адресованных dst.
+func (r *Router) NextHop(dst string, size int) (Hop, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	route, ok := r.graph.FindRoute(r.nodeID, dst, time.Now(), size)
+	if !ok {
+		return Hop{}, fmt.Errorf("%w from %s to %s", ErrNoRoute, r.nodeID, dst)
+	}
+	node := r.graph.Nodes[route.NextHop()]
+	if node.URL == "" {
+		return Hop{}, fmt.Errorf("neighbour %s has no channel url", node.ID)
+	}
+	return Hop{Node: node, Route: route, link: r.links[node.ID]}, nil
+}
@@ -0,0 +1,135 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeGraph записывает граф с сеансами, отсчитанными от текущего момента.
+func writeGraph(t *testing.T, path string, nodes []Node, contacts ...Contact) {
+	t.Helper()
+	type contactJSON struct {
+		From  string    `json:"from"`
+		To    string    `json:"to"`
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	}
+	raw := struct {
+		Nodes    []Node        `json:"nodes"`
+		Contacts []contactJSON `json:"contacts"`
+	}{Nodes: nodes}
+	for _, c := range contacts {
+		raw.Contacts = append(raw.Contacts, contactJSON{From: c.From, To: c.To, Start: c.Start, End: c.End})
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func liveContact(from, to string, start, end time.Duration) Contact {
+	c := Contact{From: from, To: to}
+	now := time.Now().Truncate(time.Second)
+	c.Start, c.End = now.Add(start), now.Add(end)
+	return c
+}
+
+func TestNextHop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.json")
+	nodes := []Node{
+		{ID: "earth"},
+		{ID: "mro", URL: "http://channel-mro", Framing: "ccsds"},
+		{ID: "tgo"},
+		{ID: "mars", URL: "http://channel-mars"},
+		{ID: "europa"},
+	}
+	writeGraph(t, path, nodes,
+		liveContact("earth", "mars", 10*time.Hour, 11*time.Hour),
+		liveContact("earth", "mro", -time.Hour, time.Hour),
+		liveContact("mro", "mars", time.Hour, 2*time.Hour),
+		liveContact("earth", "tgo", -time.Hour, time.Hour),
+		liveContact("tgo", "europa", -time.Hour, time.Hour),
+	)
+	r := NewRouter("earth", path)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dst := range []string{"mars", "mro"} {
+		hop, err := r.NextHop(dst, 100)
+		if err != nil {
+			t.Fatalf("NextHop(%s): %v", dst, err)
+		}
+		if hop.Node != nodes[1] {
+			t.Errorf("NextHop(%s) = %+v, want %+v", dst, hop.Node, nodes[1])
+		}
+	}
+	for _, dst := range []string{"earth", "phobos"} {
+		if _, err := r.NextHop(dst, 100); !errors.Is(err, ErrNoRoute) {
+			t.Errorf("NextHop(%s) error = %v, want ErrNoRoute", dst, err)
+		}
+	}
+	// Маршрут есть, но у соседа tgo нет адреса канала
+	if _, err := r.NextHop("europa", 100); err == nil || errors.Is(err, ErrNoRoute) {
+		t.Errorf("NextHop(europa) error = %v, want missing channel url", err)
+	}
+}
+
+// Сосед, пропавший из графа, забывается, а сегмент, ждущий сеанса с ним,
+// получает ErrNoRoute и может искать другой маршрут
+func TestReloadDropsRemovedNodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.json")
+	earth := Node{ID: "earth"}
+	mars := Node{ID: "mars", URL: "http://channel-mars"}
+	mro := Node{ID: "mro", URL: "http://channel-mro"}
+	writeGraph(t, path, []Node{earth, mro, mars},
+		liveContact("earth", "mro", time.Hour, 2*time.Hour),
+		liveContact("mro", "mars", 2*time.Hour, 3*time.Hour),
+	)
+	r := NewRouter("earth", path)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	hop, err := r.NextHop("mars", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- hop.Wait(context.Background(), 100) }()
+
+	writeGraph(t, path, []Node{earth, mars}, liveContact("earth", "mars", time.Hour, 2*time.Hour))
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrNoRoute) {
+			t.Fatalf("Wait error = %v, want ErrNoRoute", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait still holds the segment for a removed neighbour")
+	}
+	if _, ok := r.links["mro"]; ok {
+		t.Fatal("link to removed node mro kept after reload")
+	}
+	if hop, err := r.NextHop("mars", 100); err != nil || hop.Node.ID != "mars" {
+		t.Fatalf("NextHop after reload = %+v, %v; want mars", hop.Node, err)
+	}
+
+	// Отмена ожидания вызывающим — не ошибка маршрута
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if hop, _ := r.NextHop("mars", 100); !errors.Is(hop.Wait(ctx, 100), context.Canceled) {
+		t.Fatal("cancelled Wait must return the context error")
+	}
+}
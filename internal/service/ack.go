@@ -1,39 +1,48 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"transport/internal/config"
 	"transport/internal/model"
 )
 
-func SendFinalAck(ack model.Ack, failed bool, cfg *config.Config) error {
-	status := "success"
+// SendFinalAck ставит финальный ACK для приложения узла-отправителя в
+// outbox. ACK адресован узлу-отправителю, поэтому получатель статуса
+// берётся из ack.Destination. Фактическая доставка (с повторами)
+// выполняется циклом Outbox.Run.
+func SendFinalAck(outbox *Outbox, fanout *FanOut, ack model.Ack, failed bool, cfg *config.Config) error {
+	status := model.StatusSuccess
 	if failed {
-		status = "error"
+		status = model.StatusError
 	}
+	return SendFinalStatus(outbox, fanout, ack.MessageID, status, ack.Destination, cfg)
+}
 
+// SendFinalStatus ставит итоговый статус сообщения для приложения узла
+// source. Статус копии разосланного сообщения копится в fanout, пока не
+// завершатся все получатели, и уходит одним сводным статусом.
+func SendFinalStatus(outbox *Outbox, fanout *FanOut, messageID, status, source string, cfg *config.Config) error {
 	final := model.FinalAck{
-		MessageID: ack.MessageID,
+		MessageID: messageID,
 		Status:    status,
 	}
-
-	data, err := json.Marshal(final)
-	if err != nil {
-		return fmt.Errorf("failed to marshal final ACK: %w", err)
+	if all, part, done := fanout.Complete(messageID, status); part {
+		if !done {
+			return nil
+		}
+		final = all
 	}
 
-	url := cfg.AppEarthURL + "/receiveAck"
-	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
-	if err != nil {
-		return fmt.Errorf("failed to send final ACK to app-earth: %w", err)
+	app := cfg.AppURL(source)
+	if app == "" {
+		return fmt.Errorf("no application endpoint for node %q", source)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("final ACK not accepted, status code: %d", resp.StatusCode)
+	if err := outbox.Enqueue(app+"/receiveAck", final); err != nil {
+		if errors.Is(err, ErrNotPersisted) {
+			return fmt.Errorf("final ACK for node %q: %w", source, err)
+		}
+		return fmt.Errorf("failed to enqueue final ACK for node %q: %w", source, err)
 	}
 
 	return nil
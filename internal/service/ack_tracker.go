@@ -5,6 +5,8 @@ import (
 	"sync"
 	"time"
 	"transport/internal/config"
+	"transport/internal/contact"
+	"transport/internal/metrics"
 	"transport/internal/model"
 )
 
@@ -13,6 +15,12 @@ type AckTracker struct {
 	messages map[string]*TrackedMessage
 	timeout  time.Duration
 	cfg      *config.Config
+	outbox   *Outbox
+	webhooks *WebhookRegistry
+	events   *EventBus
+	resends  *resendQueue
+	contacts *contact.Plan
+	fanout   *FanOut
 }
 
 type TrackedMessage struct {
@@ -21,14 +29,71 @@ type TrackedMessage struct {
 	RetryCount    int
 	TotalSegments int
 	SentAt        time.Time
-	timer         *time.Timer
+	LastSentAt    time.Time
+	// Released — сегменты, ответственность за которые принял следующий
+	// узел; их содержимое освобождено, и повторно они не отправляются
+	Released []bool `json:",omitempty"`
+	timer    *time.Timer
 }
 
-func NewAckTracker(cfg *config.Config) *AckTracker {
+func (t *TrackedMessage) Sender() string {
+	if len(t.Segments) == 0 {
+		return ""
+	}
+	return t.Segments[0].Sender
+}
+
+// Source возвращает узел-отправитель, приложению которого сообщается
+// итоговый статус.
+func (t *TrackedMessage) Source() string {
+	if len(t.Segments) == 0 {
+		return ""
+	}
+	return t.Segments[0].Source
+}
+
+// ExpiresAt возвращает срок жизни сообщения; нулевое время — бессрочно.
+func (t *TrackedMessage) ExpiresAt() time.Time {
+	if len(t.Segments) == 0 || t.Segments[0].ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(t.Segments[0].ExpiresAt)
+}
+
+func (t *TrackedMessage) expired(now time.Time) bool {
+	return len(t.Segments) > 0 && t.Segments[0].Expired(now)
+}
+
+func (t *TrackedMessage) released(i int) bool {
+	return i < len(t.Released) && t.Released[i]
+}
+
+// holds сообщает, хранит ли отправитель копию хотя бы одного сегмента с
+// индексом from и дальше.
+func (t *TrackedMessage) holds(from int) bool {
+	for i := max(from, 0); i < t.TotalSegments; i++ {
+		if !t.released(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *TrackedMessage) observeDelivery(outcome string) {
+	metrics.DeliveryLatency.WithLabelValues(metrics.Sender(t.Sender()), outcome).Observe(time.Since(t.SentAt).Seconds())
+}
+
+func NewAckTracker(cfg *config.Config, outbox *Outbox, webhooks *WebhookRegistry, events *EventBus, contacts *contact.Plan, fanout *FanOut) *AckTracker {
 	return &AckTracker{
 		messages: make(map[string]*TrackedMessage),
 		timeout:  cfg.AckTimeout,
 		cfg:      cfg,
+		outbox:   outbox,
+		webhooks: webhooks,
+		events:   events,
+		resends:  newResendQueue(),
+		contacts: contacts,
+		fanout:   fanout,
 	}
 }
 
@@ -42,8 +107,9 @@ func (a *AckTracker) Track(msgID string, segments []model.Segment) {
 		RetryCount:    0,
 		TotalSegments: len(segments),
 		SentAt:        time.Now(),
+		LastSentAt:    time.Now(),
 	}
-	tracked.timer = time.AfterFunc(a.timeout, func() {
+	tracked.timer = time.AfterFunc(a.timerDelay(tracked), func() {
 		a.handleTimeout(msgID)
 	})
 
@@ -60,44 +126,85 @@ func (a *AckTracker) HandleAck(ack model.Ack) (resend []model.Segment, done bool
 		return nil, false, false
 	}
 
+	ackOutcome := metrics.OutcomeProgress
+	if ack.LastConfirmedSegment <= tracked.LastConfirmed && !ack.Final {
+		ackOutcome = metrics.OutcomeNoProgress
+	}
+	metrics.AckRoundTrip.WithLabelValues(metrics.Sender(tracked.Sender()), ackOutcome).Observe(time.Since(tracked.LastSentAt).Seconds())
+
+	a.events.Publish(model.Event{
+		Type:          model.StreamAckProcessed,
+		MessageID:     ack.MessageID,
+		Sender:        tracked.Sender(),
+		TotalSegments: tracked.TotalSegments,
+		LastConfirmed: ack.LastConfirmedSegment,
+	})
+
+	// Получатель отказался от сообщения — итоговая ошибка без повторов
+	if ack.Final && ack.Failed {
+		log.Printf("[AckTracker] Message %s rejected by receiver. Ending tracking.", ack.MessageID)
+		tracked.timer.Stop()
+		delete(a.messages, ack.MessageID)
+		tracked.observeDelivery(metrics.OutcomeError)
+		a.events.Publish(model.Event{
+			Type:          model.StreamMessageFailed,
+			MessageID:     ack.MessageID,
+			Sender:        tracked.Sender(),
+			TotalSegments: tracked.TotalSegments,
+			LastConfirmed: ack.LastConfirmedSegment,
+			Reason:        "rejected by receiver",
+		})
+		return nil, false, true
+	}
+
 	// Обработка финального ACK от Марса
 	if ack.Final {
 		log.Printf("[AckTracker] Final ACK received for message %s. Ending tracking.", ack.MessageID)
+		tracked.timer.Stop()
 		delete(a.messages, ack.MessageID)
+		tracked.observeDelivery(metrics.OutcomeSuccess)
 		return nil, true, true
 	}
 
 	// Сброс таймера
 	tracked.timer.Stop()
-	tracked.timer.Reset(a.timeout)
+	tracked.timer.Reset(a.timerDelay(tracked))
 
 	// Успешно доставлены все сегменты
 	if ack.LastConfirmedSegment == tracked.TotalSegments-1 {
 		log.Printf("[AckTracker] All segments confirmed for message %s", ack.MessageID)
+		tracked.timer.Stop()
 		delete(a.messages, ack.MessageID)
+		tracked.observeDelivery(metrics.OutcomeSuccess)
 		return nil, true, false
 	}
 
+	// Недостающие сегменты хранит следующий узел: повторять их — его забота
+	if !tracked.holds(ack.LastConfirmedSegment + 1) {
+		tracked.LastConfirmed = max(tracked.LastConfirmed, ack.LastConfirmedSegment)
+		log.Printf("[AckTracker] Missing segments of %s are in custody downstream, not resending", ack.MessageID)
+		return nil, false, false
+	}
+
 	// Нет прогресса
 	if ack.LastConfirmedSegment <= tracked.LastConfirmed {
 		tracked.RetryCount++
 		log.Printf("[AckTracker] No progress for %s. Retry #%d", ack.MessageID, tracked.RetryCount)
 
 		if tracked.RetryCount >= 1 {
-			log.Printf("[AckTracker] No progress after retry for %s. Sending Final ACK", ack.MessageID)
+			// Финальный ACK с ошибкой отправляет вызывающая сторона по fail=true
+			log.Printf("[AckTracker] No progress after retry for %s. Giving up", ack.MessageID)
+			tracked.timer.Stop()
 			delete(a.messages, ack.MessageID)
-
-			go func() {
-				err := SendFinalAck(model.Ack{
-					MessageID:            ack.MessageID,
-					LastConfirmedSegment: ack.LastConfirmedSegment,
-					Final:                true,
-				}, true, a.cfg)
-				if err != nil {
-					log.Printf("[AckTracker] Failed to send final ACK for %s: %v", ack.MessageID, err)
-				}
-			}()
-
+			tracked.observeDelivery(metrics.OutcomeError)
+			a.events.Publish(model.Event{
+				Type:          model.StreamMessageFailed,
+				MessageID:     ack.MessageID,
+				Sender:        tracked.Sender(),
+				TotalSegments: tracked.TotalSegments,
+				LastConfirmed: tracked.LastConfirmed,
+				Reason:        "no progress after retry",
+			})
 			return nil, false, true
 		}
 	} else {
@@ -107,14 +214,71 @@ func (a *AckTracker) HandleAck(ack model.Ack) (resend []model.Segment, done bool
 		log.Printf("[AckTracker] Progress detected for %s. Resetting retry count.", ack.MessageID)
 	}
 
+	// Повторять сегменты просроченного сообщения бессмысленно
+	if tracked.expired(time.Now()) {
+		a.expire(ack.MessageID, tracked)
+		return nil, false, false
+	}
+
 	// Повторная отправка недостающих сегментов
 	for i := ack.LastConfirmedSegment + 1; i < tracked.TotalSegments; i++ {
-		resend = append(resend, tracked.Segments[i])
+		if !tracked.released(i) {
+			resend = append(resend, tracked.Segments[i])
+		}
+	}
+	if len(resend) > 0 {
+		tracked.LastSentAt = time.Now()
 	}
 
 	return resend, false, false
 }
 
+// HandleCustody освобождает копии сегментов, ответственность за которые
+// принял следующий узел. Сообщение остаётся отслеживаемым до итогового ACK
+// от получателя; когда переданы все сегменты, его ждут CustodyTimeout.
+func (a *AckTracker) HandleCustody(ack model.Ack) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	tracked, ok := a.messages[ack.MessageID]
+	if !ok {
+		return
+	}
+	if tracked.Released == nil {
+		tracked.Released = make([]bool, tracked.TotalSegments)
+	}
+
+	released := 0
+	for _, i := range ack.Segments {
+		if i < 0 || i >= tracked.TotalSegments || tracked.Released[i] {
+			continue
+		}
+		tracked.Released[i] = true
+		tracked.Segments[i].Payload = ""
+		released++
+	}
+	if released == 0 {
+		return
+	}
+	metrics.SegmentsReleased.WithLabelValues(metrics.Sender(tracked.Sender()), metrics.OutcomeOK).Add(float64(released))
+
+	a.events.Publish(model.Event{
+		Type:          model.StreamCustodyAccepted,
+		MessageID:     ack.MessageID,
+		Sender:        tracked.Sender(),
+		TotalSegments: tracked.TotalSegments,
+		LastConfirmed: tracked.LastConfirmed,
+	})
+
+	if tracked.holds(0) {
+		log.Printf("[AckTracker] Custody of %d segment(s) of message %s accepted downstream", released, ack.MessageID)
+		return
+	}
+	log.Printf("[AckTracker] Custody of all segments of message %s accepted downstream, waiting for final status", ack.MessageID)
+	tracked.timer.Stop()
+	tracked.timer.Reset(a.timerDelay(tracked))
+}
+
 func (a *AckTracker) handleTimeout(messageID string) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
@@ -124,18 +288,152 @@ func (a *AckTracker) handleTimeout(messageID string) {
 		return
 	}
 
+	if tracked.expired(time.Now()) {
+		a.expire(messageID, tracked)
+		return
+	}
+
 	log.Printf("[AckTracker] Timeout exceeded for message %s. Sending Final=true ACK", messageID)
 	delete(a.messages, messageID)
+	tracked.observeDelivery(metrics.OutcomeTimeout)
+
+	a.events.Publish(model.Event{
+		Type:          model.StreamMessageFailed,
+		MessageID:     messageID,
+		Sender:        tracked.Sender(),
+		TotalSegments: tracked.TotalSegments,
+		LastConfirmed: tracked.LastConfirmed,
+		Reason:        "ack timeout",
+	})
 
-	go func() {
-		err := SendFinalAck(model.Ack{
-			MessageID:            messageID,
-			LastConfirmedSegment: tracked.LastConfirmed,
-			Final:                true,
-		}, true, a.cfg)
+	err := SendFinalAck(a.outbox, a.fanout, model.Ack{
+		MessageID:            messageID,
+		LastConfirmedSegment: tracked.LastConfirmed,
+		Final:                true,
+		Destination:          tracked.Source(),
+	}, true, a.cfg)
+	if err != nil {
+		log.Printf("[AckTracker] Failed to send final timeout ACK for %s: %v", messageID, err)
+	}
+	a.webhooks.Notify(messageID, model.EventFailed, tracked.TotalSegments, tracked.LastConfirmed)
+}
 
-		if err != nil {
-			log.Printf("[AckTracker] Failed to send final timeout ACK for %s: %v", messageID, err)
-		}
-	}()
+// timerDelay — время ожидания следующего ACK: AckTimeout (CustodyTimeout,
+// если все сегменты переданы на хранение дальше) плюс ожидание ближайшего
+// сеанса связи и световое время туда и обратно по плану, но не дольше
+// оставшегося срока жизни сообщения.
+func (a *AckTracker) timerDelay(tracked *TrackedMessage) time.Duration {
+	timeout := a.timeout
+	if !tracked.holds(0) {
+		timeout = a.cfg.CustodyTimeout
+	}
+	delay := timeout + a.contacts.AckDelay(time.Now())
+	if expiresAt := tracked.ExpiresAt(); !expiresAt.IsZero() {
+		delay = min(delay, max(time.Until(expiresAt), 0))
+	}
+	return delay
+}
+
+// expire прекращает отслеживание просроченного сообщения и сообщает
+// приложению отправителя статус expired. Вызывается под a.mu.
+func (a *AckTracker) expire(messageID string, tracked *TrackedMessage) {
+	log.Printf("[AckTracker] Message %s expired at %s with %d of %d segment(s) confirmed",
+		messageID, tracked.ExpiresAt().Format(time.RFC3339), tracked.LastConfirmed+1, tracked.TotalSegments)
+	tracked.timer.Stop()
+	delete(a.messages, messageID)
+	tracked.observeDelivery(metrics.OutcomeExpired)
+	metrics.MessagesExpired.WithLabelValues(metrics.Sender(tracked.Sender()), metrics.StageAckTracker).Inc()
+
+	a.events.Publish(model.Event{
+		Type:          model.StreamMessageFailed,
+		MessageID:     messageID,
+		Sender:        tracked.Sender(),
+		TotalSegments: tracked.TotalSegments,
+		LastConfirmed: tracked.LastConfirmed,
+		Reason:        "expired",
+	})
+
+	if err := SendFinalStatus(a.outbox, a.fanout, messageID, model.StatusExpired, tracked.Source(), a.cfg); err != nil {
+		log.Printf("[AckTracker] Failed to send expiry status for %s: %v", messageID, err)
+	}
+	a.webhooks.Notify(messageID, model.EventExpired, tracked.TotalSegments, tracked.LastConfirmed)
+}
+
+// expireIfDue завершает сообщение со статусом expired, если оно ещё
+// отслеживается и его срок истёк.
+func (a *AckTracker) expireIfDue(messageID string, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if tracked, ok := a.messages[messageID]; ok && tracked.expired(now) {
+		a.expire(messageID, tracked)
+	}
+}
+
+// Cancel прекращает отслеживание сообщения. Возвращает false, если сообщение
+// не отслеживается (уже завершено или неизвестно).
+func (a *AckTracker) Cancel(messageID string) (*TrackedMessage, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	tracked, ok := a.messages[messageID]
+	if !ok {
+		return nil, false
+	}
+
+	tracked.timer.Stop()
+	delete(a.messages, messageID)
+	tracked.observeDelivery(metrics.OutcomeCancelled)
+	log.Printf("[AckTracker] Tracking cancelled for message %s", messageID)
+
+	return tracked, true
+}
+
+// Len возвращает количество отслеживаемых сообщений.
+func (a *AckTracker) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.messages)
+}
+
+// SaveState сохраняет отслеживаемые сообщения в файл и останавливает их
+// таймеры. Вызывается при остановке сервиса.
+func (a *AckTracker) SaveState(path string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, tracked := range a.messages {
+		tracked.timer.Stop()
+	}
+
+	if err := writeJSONAtomic(path, a.messages, 0o644); err != nil {
+		return err
+	}
+	log.Printf("[AckTracker] Saved %d tracked message(s) to %s", len(a.messages), path)
+	return nil
+}
+
+// LoadState восстанавливает сообщения, сохранённые SaveState, и заново
+// запускает для них таймеры ожидания ACK.
+func (a *AckTracker) LoadState(path string) error {
+	restored := make(map[string]*TrackedMessage)
+	if err := readJSON(path, &restored); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for msgID, tracked := range restored {
+		msgID := msgID
+		tracked.timer = time.AfterFunc(a.timerDelay(tracked), func() {
+			a.handleTimeout(msgID)
+		})
+		a.messages[msgID] = tracked
+	}
+
+	if len(restored) > 0 {
+		log.Printf("[AckTracker] Restored %d tracked message(s) from %s", len(restored), path)
+	}
+	return nil
 }
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+	"transport/internal/model"
+)
+
+const subscriberBuffer = 256
+
+type EventFilter struct {
+	MessageID string
+	Sender    string
+}
+
+func (f EventFilter) Match(e model.Event) bool {
+	if f.MessageID != "" && f.MessageID != e.MessageID {
+		return false
+	}
+	if f.Sender != "" && f.Sender != e.Sender {
+		return false
+	}
+	return true
+}
+
+type subscriber struct {
+	ch      chan model.Event
+	filter  EventFilter
+	dropped atomic.Int64
+}
+
+// EventBus раздаёт события обработки сообщений подписчикам (SSE, WebSocket).
+// Медленный подписчик не блокирует конвейер: события для него отбрасываются.
+type EventBus struct {
+	mu     sync.RWMutex
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subs: make(map[*subscriber]struct{}),
+	}
+}
+
+func (b *EventBus) Subscribe(filter EventFilter) (<-chan model.Event, func()) {
+	sub := &subscriber{
+		ch:     make(chan model.Event, subscriberBuffer),
+		filter: filter,
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		close(sub.ch)
+	} else {
+		b.subs[sub] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			if _, ok := b.subs[sub]; ok {
+				delete(b.subs, sub)
+				close(sub.ch)
+			}
+			b.mu.Unlock()
+		})
+	}
+
+	return sub.ch, cancel
+}
+
+func (b *EventBus) Publish(e model.Event) {
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			if n := sub.dropped.Add(1); n%subscriberBuffer == 1 {
+				log.Printf("[Events] Slow subscriber, dropped %d event(s)", n)
+			}
+		}
+	}
+}
+
+// Close завершает все подписки.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		close(sub.ch)
+		delete(b.subs, sub)
+	}
+}
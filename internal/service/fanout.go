@@ -0,0 +1,184 @@
+package service
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"transport/internal/model"
+)
+
+// partSeparator отделяет узел назначения в идентификаторе части сообщения.
+const partSeparator = "@"
+
+// PartID возвращает идентификатор копии сообщения messageID для узла
+// destination. Копии отслеживаются независимо: у каждой свои сегменты,
+// ACK и повторы.
+func PartID(messageID, destination string) string {
+	return messageID + partSeparator + destination
+}
+
+type fanoutMessage struct {
+	MessageID string
+	// Parts — узел назначения по идентификатору части
+	Parts map[string]string
+	// Results — итоговый статус по узлу назначения; пусто — ещё в пути
+	Results map[string]string
+}
+
+// FanOut собирает итоговые статусы копий сообщения, разосланного
+// нескольким узлам, и отдаёт один итоговый статус с результатом по
+// каждому получателю, когда завершены все копии.
+type FanOut struct {
+	mu       sync.Mutex
+	messages map[string]*fanoutMessage
+	parts    map[string]*fanoutMessage
+}
+
+func NewFanOut() *FanOut {
+	return &FanOut{
+		messages: make(map[string]*fanoutMessage),
+		parts:    make(map[string]*fanoutMessage),
+	}
+}
+
+// Start регистрирует рассылку сообщения messageID узлам destinations.
+func (f *FanOut) Start(messageID string, destinations []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m := &fanoutMessage{
+		MessageID: messageID,
+		Parts:     make(map[string]string, len(destinations)),
+		Results:   make(map[string]string, len(destinations)),
+	}
+	for _, dst := range destinations {
+		m.Parts[PartID(messageID, dst)] = dst
+		m.Results[dst] = ""
+	}
+	f.add(m)
+	log.Printf("[FanOut] Message %s fanned out to %s", messageID, strings.Join(destinations, ", "))
+}
+
+// Drop забывает рассылку, ни одна копия которой не отправлена.
+func (f *FanOut) Drop(messageID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m, ok := f.messages[messageID]
+	if !ok {
+		return
+	}
+	for id := range m.Parts {
+		delete(f.parts, id)
+	}
+	delete(f.messages, messageID)
+}
+
+// Parts возвращает идентификаторы частей рассылки messageID; false — это
+// не рассылка нескольким узлам.
+func (f *FanOut) Parts(messageID string) ([]string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m, ok := f.messages[messageID]
+	if !ok {
+		return nil, false
+	}
+	ids := make([]string, 0, len(m.Parts))
+	for id := range m.Parts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, true
+}
+
+// Complete записывает итоговый статус части partID. part=false — partID
+// не часть рассылки. done=true — завершены все части, final содержит
+// сводный статус и результат по каждому получателю.
+func (f *FanOut) Complete(partID, status string) (final model.FinalAck, part bool, done bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	m, ok := f.parts[partID]
+	if !ok {
+		return model.FinalAck{}, false, false
+	}
+	dst := m.Parts[partID]
+	if m.Results[dst] != "" {
+		// Повторный статус части (например, ACK после таймаута) не меняет итог
+		return model.FinalAck{}, true, false
+	}
+	m.Results[dst] = status
+	log.Printf("[FanOut] Message %s to %s finished with status %s", m.MessageID, dst, status)
+
+	for _, s := range m.Results {
+		if s == "" {
+			return model.FinalAck{}, true, false
+		}
+	}
+
+	for id := range m.Parts {
+		delete(f.parts, id)
+	}
+	delete(f.messages, m.MessageID)
+	return m.final(), true, true
+}
+
+// final сводит результаты: общий статус, если он у всех получателей
+// одинаков, иначе partial.
+func (m *fanoutMessage) final() model.FinalAck {
+	final := model.FinalAck{MessageID: m.MessageID}
+	for dst, status := range m.Results {
+		final.Recipients = append(final.Recipients, model.RecipientStatus{Destination: dst, Status: status})
+		switch final.Status {
+		case "", status:
+			final.Status = status
+		default:
+			final.Status = model.StatusPartial
+		}
+	}
+	sort.Slice(final.Recipients, func(i, j int) bool {
+		return final.Recipients[i].Destination < final.Recipients[j].Destination
+	})
+	return final
+}
+
+// add индексирует рассылку. Вызывается под f.mu.
+func (f *FanOut) add(m *fanoutMessage) {
+	f.messages[m.MessageID] = m
+	for id := range m.Parts {
+		f.parts[id] = m
+	}
+}
+
+// SaveState сохраняет незавершённые рассылки в файл.
+func (f *FanOut) SaveState(path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := writeJSONAtomic(path, f.messages, 0o644); err != nil {
+		return err
+	}
+	log.Printf("[FanOut] Saved %d pending fan-out message(s) to %s", len(f.messages), path)
+	return nil
+}
+
+// LoadState восстанавливает рассылки, сохранённые SaveState.
+func (f *FanOut) LoadState(path string) error {
+	restored := make(map[string]*fanoutMessage)
+	if err := readJSON(path, &restored); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, m := range restored {
+		f.add(m)
+	}
+	if len(restored) > 0 {
+		log.Printf("[FanOut] Restored %d pending fan-out message(s) from %s", len(restored), path)
+	}
+	return nil
+}
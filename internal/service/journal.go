@@ -0,0 +1,129 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"transport/internal/model"
+)
+
+// journalRecord — запись журнала сборки: принятый сегмент или отметка, что
+// сообщение покинуло буфер (доставлено, просрочено или отброшено).
+type journalRecord struct {
+	Segment *model.Segment `json:"segment,omitempty"`
+	Done    string         `json:"done,omitempty"`
+}
+
+// reassemblyJournal — журнал буфера сборки на диске. Каждая запись
+// сбрасывается на диск до ответа каналу, поэтому сегмент, о хранении
+// которого узел сообщил предыдущему хранителю, переживает падение узла.
+type reassemblyJournal struct {
+	path    string
+	f       *os.File
+	records int
+}
+
+// openJournal открывает журнал и возвращает его записи. Повреждённые
+// строки и недописанный хвост пропускаются.
+func openJournal(path string) (*reassemblyJournal, []journalRecord, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	var records []journalRecord
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var rec journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			log.Printf("[Reassembler] Skipping corrupt journal record in %s: %v", path, err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	j := &reassemblyJournal{path: path}
+	if err := j.reopen(); err != nil {
+		return nil, nil, err
+	}
+	j.records = len(records)
+	return j, records, nil
+}
+
+func (j *reassemblyJournal) reopen() error {
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	j.f = f
+	return nil
+}
+
+// append дописывает запись и сбрасывает её на диск. При ошибке файл
+// обрезается до прежнего размера, чтобы не оставить недописанную строку.
+func (j *reassemblyJournal) append(rec journalRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	info, err := j.f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		_ = j.f.Truncate(info.Size())
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		_ = j.f.Truncate(info.Size())
+		return err
+	}
+	j.records++
+	return nil
+}
+
+// rewrite заменяет журнал сегментами, которые ещё лежат в буфере.
+func (j *reassemblyJournal) rewrite(segments []model.Segment) error {
+	var data []byte
+	for i := range segments {
+		line, err := json.Marshal(journalRecord{Segment: &segments[i]})
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+
+	j.f.Close()
+	j.records = len(segments)
+	return j.reopen()
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"transport/internal/config"
+)
+
+// ErrNotPersisted возвращается EnqueueRaw, если запись не удалось сохранить
+// на диск: она будет доставлена, но потеряется при перезапуске узла.
+var ErrNotPersisted = errors.New("outbox entry kept in memory only")
+
+type outboxEntry struct {
+	ID          string            `json:"id"`
+	URL         string            `json:"url"`
+	Body        json.RawMessage   `json:"body"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Attempts    int               `json:"attempts"`
+	NextAttempt time.Time         `json:"nextAttempt"`
+	CreatedAt   time.Time         `json:"createdAt"`
+}
+
+// Outbox хранит исходящие уведомления на диске и повторяет их доставку,
+// пока получатель не ответит 2xx. Запись, исчерпавшая maxAttempts попыток
+// или прожившая дольше ttl, отбрасывается; нули — без ограничений.
+type Outbox struct {
+	mu          sync.Mutex
+	name        string
+	path        string
+	entries     []*outboxEntry
+	seq         int64
+	client      *http.Client
+	minDelay    time.Duration
+	maxDelay    time.Duration
+	maxAttempts int
+	ttl         time.Duration
+	wake        chan struct{}
+	lastErr     error
+	// busy — адреса, доставка на которые идёт сейчас; каждый адрес
+	// обслуживает своя горутина, чтобы зависший получатель не задерживал
+	// остальных
+	busy     map[string]bool
+	inflight sync.WaitGroup
+}
+
+// NewOutbox создаёт outbox финальных ACK: итоговый статус доставляется
+// приложению без ограничения числа попыток.
+func NewOutbox(cfg *config.Config) *Outbox {
+	return newOutbox("Outbox", cfg.OutboxPath, 0, 0, cfg)
+}
+
+// NewWebhookOutbox создаёт отдельный outbox событий webhook: недоступный
+// callback URL не задерживает финальные ACK, а его события отбрасываются
+// после WEBHOOK_MAX_ATTEMPTS попыток или по истечении WEBHOOK_TTL.
+func NewWebhookOutbox(cfg *config.Config) *Outbox {
+	o := newOutbox("WebhookOutbox", cfg.WebhookOutboxPath, cfg.WebhookMaxAttempts, cfg.WebhookTTL, cfg)
+	// Перенаправление увело бы запрос мимо WEBHOOK_ALLOWED_HOSTS: ответ 3xx
+	// считается ошибкой доставки
+	o.client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return o
+}
+
+func newOutbox(name, path string, maxAttempts int, ttl time.Duration, cfg *config.Config) *Outbox {
+	o := &Outbox{
+		name:        name,
+		path:        path,
+		client:      &http.Client{Timeout: cfg.OutboxRequestTimeout},
+		minDelay:    cfg.OutboxRetryMin,
+		maxDelay:    cfg.OutboxRetryMax,
+		maxAttempts: maxAttempts,
+		ttl:         ttl,
+		wake:        make(chan struct{}, 1),
+		busy:        make(map[string]bool),
+	}
+
+	if err := o.load(); err != nil {
+		log.Printf("[%s] Failed to load %s: %v", o.name, o.path, err)
+	} else if len(o.entries) > 0 {
+		log.Printf("[%s] Restored %d pending deliveries from %s", o.name, len(o.entries), o.path)
+	}
+
+	return o
+}
+
+// Enqueue сохраняет payload и планирует его немедленную отправку на url.
+func (o *Outbox) Enqueue(url string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+	return o.EnqueueRaw(url, data, nil)
+}
+
+// EnqueueRaw ставит в очередь уже сериализованное тело с дополнительными
+// заголовками (например, подписью webhook). Ошибка записи на диск не
+// отменяет доставку: запись остаётся в памяти, EnqueueRaw возвращает
+// ErrNotPersisted, а /readyz сообщает об ошибке хранилища до следующей
+// удачной записи.
+func (o *Outbox) EnqueueRaw(url string, data []byte, headers map[string]string) error {
+	var persistErr error
+	o.mu.Lock()
+	o.seq++
+	now := time.Now()
+	o.entries = append(o.entries, &outboxEntry{
+		ID:          fmt.Sprintf("%d-%d", now.UnixNano(), o.seq),
+		URL:         url,
+		Body:        data,
+		Headers:     headers,
+		NextAttempt: now,
+		CreatedAt:   now,
+	})
+	if err := o.persist(); err != nil {
+		log.Printf("[%s] Failed to persist outbox, entry kept in memory: %v", o.name, err)
+		persistErr = fmt.Errorf("%w: %v", ErrNotPersisted, err)
+	}
+	o.mu.Unlock()
+
+	o.notify()
+	return persistErr
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pending возвращает количество ещё не доставленных записей.
+func (o *Outbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+func (o *Outbox) Run(ctx context.Context) {
+	log.Printf("[%s] Delivery loop started", o.name)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			o.inflight.Wait()
+			log.Printf("[%s] Delivery loop stopped", o.name)
+			return
+		case <-timer.C:
+		case <-o.wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+
+		o.flushDue(ctx)
+		timer.Reset(o.nextWait())
+	}
+}
+
+// flushDue запускает доставку созревших записей: по горутине на адрес,
+// на который сейчас ничего не доставляется. Записи одного адреса уходят по
+// порядку постановки.
+func (o *Outbox) flushDue(ctx context.Context) {
+	now := time.Now()
+
+	o.mu.Lock()
+	due := make(map[string][]*outboxEntry)
+	for _, e := range o.entries {
+		if !o.busy[e.URL] && !e.NextAttempt.After(now) {
+			due[e.URL] = append(due[e.URL], e)
+		}
+	}
+	for url := range due {
+		o.busy[url] = true
+	}
+	o.mu.Unlock()
+
+	for url, entries := range due {
+		o.inflight.Add(1)
+		go o.deliverTo(ctx, url, entries)
+	}
+}
+
+// deliverTo доставляет записи на один адрес и сохраняет outbox один раз
+// после всех попыток.
+func (o *Outbox) deliverTo(ctx context.Context, url string, entries []*outboxEntry) {
+	defer o.inflight.Done()
+
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+
+		// Запись могла исчерпать время жизни, пока ждала повтора
+		o.mu.Lock()
+		expired := o.exhausted(e, time.Now())
+		o.mu.Unlock()
+		var err error
+		if !expired {
+			err = o.deliver(ctx, e)
+		}
+
+		o.mu.Lock()
+		switch {
+		case expired:
+			log.Printf("[%s] Giving up on %s to %s after %d attempt(s)", o.name, e.ID, e.URL, e.Attempts)
+			o.remove(e.ID)
+		case err == nil:
+			log.Printf("[%s] Delivered %s to %s after %d attempt(s)", o.name, e.ID, e.URL, e.Attempts+1)
+			o.remove(e.ID)
+		default:
+			e.Attempts++
+			if o.exhausted(e, time.Now()) {
+				log.Printf("[%s] Delivery %s to %s failed (attempt #%d): %v. Giving up", o.name, e.ID, e.URL, e.Attempts, err)
+				o.remove(e.ID)
+				break
+			}
+			delay := o.backoff(e.Attempts)
+			e.NextAttempt = time.Now().Add(delay)
+			log.Printf("[%s] Delivery %s to %s failed (attempt #%d): %v. Next try in %v", o.name, e.ID, e.URL, e.Attempts, err, delay)
+		}
+		o.mu.Unlock()
+	}
+
+	o.mu.Lock()
+	delete(o.busy, url)
+	if err := o.persist(); err != nil {
+		log.Printf("[%s] Failed to persist outbox: %v", o.name, err)
+	}
+	o.mu.Unlock()
+
+	// Пока адрес был занят, для него могли созреть новые записи
+	o.notify()
+}
+
+// exhausted сообщает, исчерпала ли запись попытки или время жизни.
+func (o *Outbox) exhausted(e *outboxEntry, now time.Time) bool {
+	return (o.maxAttempts > 0 && e.Attempts >= o.maxAttempts) ||
+		(o.ttl > 0 && now.Sub(e.CreatedAt) >= o.ttl)
+}
+
+func (o *Outbox) deliver(ctx context.Context, e *outboxEntry) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(e.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (o *Outbox) backoff(attempts int) time.Duration {
+	delay := o.minDelay
+	for i := 1; i < attempts && delay < o.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > o.maxDelay {
+		delay = o.maxDelay
+	}
+	return delay
+}
+
+func (o *Outbox) nextWait() time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	wait := o.maxDelay
+	now := time.Now()
+	for _, e := range o.entries {
+		// Занятый адрес разбудит цикл сам, когда освободится
+		if o.busy[e.URL] {
+			continue
+		}
+		if d := e.NextAttempt.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+func (o *Outbox) remove(id string) {
+	for i, e := range o.entries {
+		if e.ID == id {
+			o.entries = append(o.entries[:i], o.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (o *Outbox) load() error {
+	if o.path == "" {
+		return nil
+	}
+	return readJSON(o.path, &o.entries)
+}
+
+// Check сообщает о состоянии хранилища outbox: последнюю ошибку записи
+// и доступность каталога для записи.
+func (o *Outbox) Check() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.lastErr != nil {
+		return fmt.Errorf("last persist failed: %w", o.lastErr)
+	}
+	if o.path == "" {
+		return nil
+	}
+
+	dir := filepath.Dir(o.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".probe-*")
+	if err != nil {
+		return fmt.Errorf("outbox directory not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// persist вызывается под o.mu.
+func (o *Outbox) persist() error {
+	err := o.write()
+	o.lastErr = err
+	return err
+}
+
+func (o *Outbox) write() error {
+	if o.path == "" {
+		return nil
+	}
+	return writeJSONAtomic(o.path, o.entries, 0o644)
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"transport/internal/config"
+)
+
+// Запись, которую не удалось сохранить, остаётся в памяти, но вызывающий и
+// /readyz узнают, что она не переживёт перезапуск
+func TestEnqueuePersistError(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	o := newOutbox("Outbox", filepath.Join(blocker, "outbox.json"), 0, 0, &config.Config{})
+
+	err := o.Enqueue("http://app-earth/receiveAck", map[string]string{"messageId": "m1"})
+	if !errors.Is(err, ErrNotPersisted) {
+		t.Fatalf("Enqueue error = %v, want ErrNotPersisted", err)
+	}
+	if o.Pending() != 1 {
+		t.Fatalf("pending = %d, want 1", o.Pending())
+	}
+	if err := o.Check(); err == nil {
+		t.Fatal("Check reports a healthy store after a failed persist")
+	}
+
+	o.path = filepath.Join(dir, "outbox.json")
+	if err := o.Enqueue("http://app-earth/receiveAck", map[string]string{"messageId": "m2"}); err != nil {
+		t.Fatalf("Enqueue after store recovered: %v", err)
+	}
+	if err := o.Check(); err != nil {
+		t.Fatalf("Check after store recovered: %v", err)
+	}
+}
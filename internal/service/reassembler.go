@@ -2,43 +2,125 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"io"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
+	"transport/internal/auth"
 	"transport/internal/config"
+	"transport/internal/framing"
+	"transport/internal/keyring"
+	"transport/internal/metrics"
 	"transport/internal/model"
+	"transport/internal/routing"
 )
 
 type bufferedMessage struct {
 	Sender         string
+	Source         string
+	Destination    string
 	Segments       map[int]string
 	ReceivedAt     time.Time
 	TotalSegments  int
+	GreenSegments  int
+	Priority       model.Priority
+	ExpiresAt      int64
 	LastConfirmed  int
 	FailedAttempts int
 }
 
+// redSegments — число red-сегментов: только они подтверждаются ACK.
+func (b *bufferedMessage) redSegments() int {
+	return b.TotalSegments - b.GreenSegments
+}
+
+func newBufferedMessage(seg model.Segment) *bufferedMessage {
+	return &bufferedMessage{
+		Sender:         seg.Sender,
+		Source:         seg.Source,
+		Destination:    seg.Destination,
+		Segments:       make(map[int]string),
+		TotalSegments:  seg.TotalSegments,
+		GreenSegments:  seg.GreenSegments,
+		Priority:       seg.Priority.Normalize(),
+		ExpiresAt:      seg.ExpiresAt,
+		ReceivedAt:     time.Now(),
+		LastConfirmed:  -1,
+		FailedAttempts: 0,
+	}
+}
+
+// segment восстанавливает сегмент index сообщения messageID для журнала.
+func (b *bufferedMessage) segment(messageID string, index int) model.Segment {
+	return model.Segment{
+		Sender:        b.Sender,
+		MessageID:     messageID,
+		SegmentIndex:  index,
+		TotalSegments: b.TotalSegments,
+		Payload:       b.Segments[index],
+		GreenSegments: b.GreenSegments,
+		Priority:      b.Priority,
+		ExpiresAt:     b.ExpiresAt,
+		Source:        b.Source,
+		Destination:   b.Destination,
+	}
+}
+
+// missingGreen возвращает индексы green-сегментов, которые ещё не получены.
+func (b *bufferedMessage) missingGreen() []int {
+	var missing []int
+	for i := b.redSegments(); i < b.TotalSegments; i++ {
+		if _, ok := b.Segments[i]; !ok {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
 type Reassembler struct {
 	mu      sync.Mutex
 	buffer  map[string]*bufferedMessage
 	timeout time.Duration
 	cfg     *config.Config
+	events  *EventBus
+	router  *routing.Router
+	keyring *keyring.Keyring
+	auth    *auth.Authenticator
+	// journal — журнал буфера на диске; nil — буфер живёт только в памяти
+	journal *reassemblyJournal
 }
 
-func NewReassembler(cfg *config.Config) *Reassembler {
+func NewReassembler(cfg *config.Config, events *EventBus, router *routing.Router, keys *keyring.Keyring, signer *auth.Authenticator) *Reassembler {
 	return &Reassembler{
 		buffer:  make(map[string]*bufferedMessage),
 		timeout: cfg.Timeout,
 		cfg:     cfg,
+		events:  events,
+		router:  router,
+		keyring: keys,
+		auth:    signer,
 	}
 }
 
-func (r *Reassembler) AddSegment(seg model.Segment) {
+// AddSegment сохраняет сегмент в буфере сборки. Возвращает false, если
+// сегмент отброшен.
+func (r *Reassembler) AddSegment(seg model.Segment) bool {
 	if seg.MessageID == "" {
 		log.Println("[ERROR] Received segment with empty messageId!")
-		return
+		metrics.SegmentsReceived.WithLabelValues(metrics.Sender(seg.Sender), metrics.OutcomeInvalid).Inc()
+		metrics.SegmentsDropped.WithLabelValues(metrics.Sender(seg.Sender), metrics.ReasonEmptyMessageID).Inc()
+		return false
+	}
+
+	if seg.Expired(time.Now()) {
+		log.Printf("[Reassembler] Segment %d of message %s arrived expired, dropping", seg.SegmentIndex, seg.MessageID)
+		metrics.SegmentsReceived.WithLabelValues(metrics.Sender(seg.Sender), metrics.OutcomeExpired).Inc()
+		metrics.SegmentsExpired.WithLabelValues(metrics.Sender(seg.Sender), metrics.StageReassembler).Inc()
+		return false
 	}
 
 	r.mu.Lock()
@@ -46,21 +128,51 @@ func (r *Reassembler) AddSegment(seg model.Segment) {
 
 	buf, ok := r.buffer[seg.MessageID]
 	if !ok {
-		buf = &bufferedMessage{
-			Sender:         seg.Sender,
-			Segments:       make(map[int]string),
-			TotalSegments:  seg.TotalSegments,
-			ReceivedAt:     time.Now(),
-			LastConfirmed:  -1,
-			FailedAttempts: 0,
-		}
+		buf = newBufferedMessage(seg)
 		r.buffer[seg.MessageID] = buf
-		log.Printf("[Reassembler] New message %s from %s. Total segments: %d", seg.MessageID, seg.Sender, seg.TotalSegments)
+		log.Printf("[Reassembler] New message %s from %s. Total segments: %d, priority %s",
+			seg.MessageID, seg.Sender, seg.TotalSegments, buf.Priority)
+	}
+
+	if _, dup := buf.Segments[seg.SegmentIndex]; dup {
+		metrics.SegmentsReceived.WithLabelValues(metrics.Sender(seg.Sender), metrics.OutcomeDuplicate).Inc()
+		metrics.SegmentsDuplicate.WithLabelValues(metrics.Sender(seg.Sender), metrics.OutcomeDuplicate).Inc()
+	} else if r.cfg.ReassemblyMaxSegments > 0 && !r.evict(seg.MessageID) {
+		// Места не освободить, не вытеснив само сообщение: сегмент
+		// отбрасывается, отправитель повторит его по ACK
+		log.Printf("[Reassembler] Buffer full (limit %d), dropping segment %d of message %s",
+			r.cfg.ReassemblyMaxSegments, seg.SegmentIndex, seg.MessageID)
+		metrics.SegmentsReceived.WithLabelValues(metrics.Sender(seg.Sender), metrics.OutcomeRejected).Inc()
+		metrics.SegmentsDropped.WithLabelValues(metrics.Sender(seg.Sender), metrics.ReasonBufferFull).Inc()
+		if len(buf.Segments) == 0 {
+			delete(r.buffer, seg.MessageID)
+		}
+		return false
+	} else if err := r.persist(seg); err != nil {
+		log.Printf("[Reassembler] Failed to journal segment %d of message %s, dropping it: %v",
+			seg.SegmentIndex, seg.MessageID, err)
+		metrics.SegmentsReceived.WithLabelValues(metrics.Sender(seg.Sender), metrics.OutcomeError).Inc()
+		if len(buf.Segments) == 0 {
+			delete(r.buffer, seg.MessageID)
+		}
+		return false
+	} else {
+		metrics.SegmentsReceived.WithLabelValues(metrics.Sender(seg.Sender), metrics.OutcomeStored).Inc()
 	}
 
 	buf.Segments[seg.SegmentIndex] = seg.Payload
 	buf.ReceivedAt = time.Now()
 	log.Printf("[Reassembler] Stored segment %d of message %s", seg.SegmentIndex, seg.MessageID)
+
+	r.events.Publish(model.Event{
+		Type:          model.StreamSegmentReceived,
+		MessageID:     seg.MessageID,
+		Sender:        seg.Sender,
+		SegmentIndex:  seg.SegmentIndex,
+		TotalSegments: seg.TotalSegments,
+		LastConfirmed: buf.LastConfirmed,
+	})
+	return true
 }
 
 func (r *Reassembler) CheckTimeoutsAndAssemble() {
@@ -73,26 +185,77 @@ func (r *Reassembler) CheckTimeoutsAndAssemble() {
 			continue
 		}
 
-		currentConfirmed := calculateLastConfirmedIndex(buf.Segments, buf.TotalSegments)
+		// Просроченное сообщение не доставляется, даже если собрано. ACK не
+		// отправляется: финальный ACK отправитель принял бы за успех, а статус
+		// expired он выставит сам по сроку жизни
+		if buf.ExpiresAt > 0 && now.UnixMilli() >= buf.ExpiresAt {
+			log.Printf("[Reassembler] Message %s expired with %d of %d segment(s) received. Dropping",
+				messageID, len(buf.Segments), buf.TotalSegments)
+			metrics.MessagesExpired.WithLabelValues(metrics.Sender(buf.Sender), metrics.StageReassembler).Inc()
+			metrics.SegmentsExpired.WithLabelValues(metrics.Sender(buf.Sender), metrics.StageReassembler).Add(float64(len(buf.Segments)))
+
+			r.events.Publish(model.Event{
+				Type:          model.StreamMessageFailed,
+				MessageID:     messageID,
+				Sender:        buf.Sender,
+				TotalSegments: buf.TotalSegments,
+				LastConfirmed: buf.LastConfirmed,
+				Reason:        "expired",
+			})
+
+			r.remove(messageID)
+			continue
+		}
+
+		red := buf.redSegments()
+		currentConfirmed := calculateLastConfirmedIndex(buf.Segments, red)
+
+		if currentConfirmed == red-1 {
+			// Red-часть собрана; green-сегменты ждём не дольше GreenWait
+			// с момента последнего полученного сегмента
+			missing := buf.missingGreen()
+			if len(missing) > 0 && now.Sub(buf.ReceivedAt) < r.cfg.GreenWait {
+				continue
+			}
 
-		if currentConfirmed == buf.TotalSegments-1 {
-			log.Printf("[Reassembler] Message %s fully received. Assembling...", messageID)
+			if len(missing) > 0 {
+				log.Printf("[Reassembler] Message %s red part received, %d of %d green segment(s) missing. Assembling...",
+					messageID, len(missing), buf.GreenSegments)
+				metrics.SegmentsDropped.WithLabelValues(metrics.Sender(buf.Sender), metrics.ReasonGreenLost).Add(float64(len(missing)))
+			} else {
+				log.Printf("[Reassembler] Message %s fully received. Assembling...", messageID)
+			}
 
+			content, hasError := r.open(messageID, buf, len(missing) > 0)
 			msg := model.Message{
-				Sender:    buf.Sender,
-				Content:   joinSegments(buf.Segments, buf.TotalSegments),
-				Timestamp: time.Now(),
-				HasError:  false,
+				Sender:               buf.Sender,
+				Content:              content,
+				Timestamp:            time.Now(),
+				HasError:             hasError,
+				MissingGreenSegments: missing,
+				Source:               buf.Source,
 			}
-			go sendMessageToAppMars(msg, r.cfg)
+			go deliverToApp(messageID, msg, buf.Destination, r.cfg)
 
-			go sendAckToChannel(model.Ack{
-				MessageID:            messageID,
-				LastConfirmedSegment: currentConfirmed,
-				Final:                true,
-			}, r.cfg)
+			r.events.Publish(model.Event{
+				Type:          model.StreamMessageAssembled,
+				MessageID:     messageID,
+				Sender:        buf.Sender,
+				TotalSegments: buf.TotalSegments,
+				LastConfirmed: currentConfirmed,
+			})
 
-			delete(r.buffer, messageID)
+			// Полностью green-сообщение отправитель не отслеживает — ACK не нужен
+			if red > 0 {
+				go SendAckToChannel(model.Ack{
+					MessageID:            messageID,
+					LastConfirmedSegment: currentConfirmed,
+					Final:                true,
+					Destination:          buf.Source,
+				}, r.cfg, r.router, r.auth)
+			}
+
+			r.remove(messageID)
 			continue
 		}
 
@@ -108,27 +271,254 @@ func (r *Reassembler) CheckTimeoutsAndAssemble() {
 
 			if buf.FailedAttempts >= 2 {
 				log.Printf("[Reassembler] Message %s failed after %d timeouts. Sending final ACK", messageID, buf.FailedAttempts)
+				metrics.SegmentsDropped.WithLabelValues(metrics.Sender(buf.Sender), metrics.ReasonReassemblyTimeout).Add(float64(len(buf.Segments)))
 
-				go sendAckToChannel(model.Ack{
+				r.events.Publish(model.Event{
+					Type:          model.StreamMessageFailed,
+					MessageID:     messageID,
+					Sender:        buf.Sender,
+					TotalSegments: buf.TotalSegments,
+					LastConfirmed: buf.LastConfirmed,
+					Reason:        "reassembly timeout",
+				})
+
+				go SendAckToChannel(model.Ack{
 					MessageID:            messageID,
 					LastConfirmedSegment: buf.LastConfirmed,
 					Final:                true,
-				}, r.cfg)
+					Failed:               true,
+					Destination:          buf.Source,
+				}, r.cfg, r.router, r.auth)
 
-				delete(r.buffer, messageID)
+				r.remove(messageID)
 			} else {
 				log.Printf("[Reassembler] Message %s timed out. Sending intermediate ACK", messageID)
 
-				go sendAckToChannel(model.Ack{
+				go SendAckToChannel(model.Ack{
 					MessageID:            messageID,
 					LastConfirmedSegment: buf.LastConfirmed,
 					Final:                false,
-				}, r.cfg)
+					Destination:          buf.Source,
+				}, r.cfg, r.router, r.auth)
 
 				buf.ReceivedAt = now
 			}
 		}
 	}
+
+	r.compactJournal()
+}
+
+// evict освобождает место для нового сегмента сообщения keep, вытесняя
+// другие сообщения, пока буфер с ним превышал бы ReassemblyMaxSegments:
+// сначала наименее срочного класса, внутри класса — дольше всех не
+// получавшие сегментов. Само сообщение keep не вытесняется; false — места
+// без него не хватает. Отправителю вытесненного сообщения уходит
+// финальный ACK с ошибкой. Вызывается под r.mu.
+func (r *Reassembler) evict(keep string) bool {
+	total := 0
+	for _, buf := range r.buffer {
+		total += len(buf.Segments)
+	}
+
+	for total+1 > r.cfg.ReassemblyMaxSegments {
+		var victimID string
+		var victim *bufferedMessage
+		for id, buf := range r.buffer {
+			if id == keep || len(buf.Segments) == 0 {
+				continue
+			}
+			if victim == nil || buf.Priority.Rank() > victim.Priority.Rank() ||
+				(buf.Priority.Rank() == victim.Priority.Rank() && buf.ReceivedAt.Before(victim.ReceivedAt)) {
+				victimID, victim = id, buf
+			}
+		}
+		if victim == nil {
+			return false
+		}
+
+		confirmed := calculateLastConfirmedIndex(victim.Segments, victim.redSegments())
+		log.Printf("[Reassembler] Buffer holds %d segments (limit %d). Evicting %s message %s with %d segment(s)",
+			total, r.cfg.ReassemblyMaxSegments, victim.Priority, victimID, len(victim.Segments))
+		metrics.SegmentsDropped.WithLabelValues(metrics.Sender(victim.Sender), metrics.ReasonEvicted).Add(float64(len(victim.Segments)))
+
+		r.events.Publish(model.Event{
+			Type:          model.StreamMessageFailed,
+			MessageID:     victimID,
+			Sender:        victim.Sender,
+			TotalSegments: victim.TotalSegments,
+			LastConfirmed: confirmed,
+			Reason:        "evicted from reassembly buffer",
+		})
+
+		if victim.redSegments() > 0 {
+			go SendAckToChannel(model.Ack{
+				MessageID:            victimID,
+				LastConfirmedSegment: confirmed,
+				Final:                true,
+				Failed:               true,
+				Destination:          victim.Source,
+			}, r.cfg, r.router, r.auth)
+		}
+
+		total -= len(victim.Segments)
+		r.remove(victimID)
+	}
+	return true
+}
+
+// remove убирает сообщение из буфера и отмечает это в журнале, чтобы после
+// перезапуска оно не было собрано и доставлено повторно. Вызывается под r.mu.
+func (r *Reassembler) remove(messageID string) {
+	delete(r.buffer, messageID)
+	if r.journal == nil {
+		return
+	}
+	if err := r.journal.append(journalRecord{Done: messageID}); err != nil {
+		log.Printf("[Reassembler] Failed to journal removal of message %s: %v", messageID, err)
+	}
+}
+
+// persist записывает сегмент в журнал; без журнала ничего не делает.
+// Вызывается под r.mu.
+func (r *Reassembler) persist(seg model.Segment) error {
+	if r.journal == nil {
+		return nil
+	}
+	seg.Auth = model.Auth{}
+	seg.Custodian = ""
+	return r.journal.append(journalRecord{Segment: &seg})
+}
+
+// journalSlack — сколько лишних записей журнал копит до сжатия.
+const journalSlack = 1024
+
+// compactJournal переписывает журнал, когда записей о покинувших буфер
+// сообщениях в нём становится больше, чем сегментов в буфере. Вызывается под r.mu.
+func (r *Reassembler) compactJournal() {
+	if r.journal == nil {
+		return
+	}
+	buffered := 0
+	for _, buf := range r.buffer {
+		buffered += len(buf.Segments)
+	}
+	if r.journal.records <= 2*buffered+journalSlack {
+		return
+	}
+
+	if err := r.journal.rewrite(r.bufferedSegments()); err != nil {
+		log.Printf("[Reassembler] Failed to compact journal: %v", err)
+	}
+}
+
+// bufferedSegments возвращает все сегменты буфера. Вызывается под r.mu.
+func (r *Reassembler) bufferedSegments() []model.Segment {
+	var segments []model.Segment
+	for id, buf := range r.buffer {
+		for i := range buf.Segments {
+			segments = append(segments, buf.segment(id, i))
+		}
+	}
+	return segments
+}
+
+// OpenJournal включает журнал буфера сборки в файле path и восстанавливает
+// из него сегменты, принятые до падения узла. Вызывается после LoadState.
+func (r *Reassembler) OpenJournal(path string) error {
+	j, records, err := openJournal(path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	restored := 0
+	for _, rec := range records {
+		if rec.Done != "" {
+			delete(r.buffer, rec.Done)
+			continue
+		}
+		if rec.Segment == nil || rec.Segment.MessageID == "" {
+			continue
+		}
+		seg := *rec.Segment
+		buf, ok := r.buffer[seg.MessageID]
+		if !ok {
+			buf = newBufferedMessage(seg)
+			r.buffer[seg.MessageID] = buf
+		}
+		if _, dup := buf.Segments[seg.SegmentIndex]; !dup {
+			buf.Segments[seg.SegmentIndex] = seg.Payload
+			restored++
+		}
+	}
+	r.journal = j
+
+	if restored > 0 {
+		log.Printf("[Reassembler] Restored %d segment(s) from journal %s", restored, path)
+	}
+	// Журнал переписывается целиком: в буфере могут быть и сообщения,
+	// восстановленные LoadState
+	if err := j.rewrite(r.bufferedSegments()); err != nil {
+		r.journal = nil
+		return err
+	}
+	return nil
+}
+
+// Durable сообщает, переживает ли буфер сборки падение узла: только тогда
+// узел назначения может принять ответственность за сегменты.
+func (r *Reassembler) Durable() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.journal != nil
+}
+
+// Stats возвращает количество сообщений и сегментов в буфере сборки.
+func (r *Reassembler) Stats() (messages, segments int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, buf := range r.buffer {
+		segments += len(buf.Segments)
+	}
+	return len(r.buffer), segments
+}
+
+// SaveState сохраняет буфер незавершённых сообщений в файл.
+func (r *Reassembler) SaveState(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := writeJSONAtomic(path, r.buffer, 0o644); err != nil {
+		return err
+	}
+	log.Printf("[Reassembler] Saved %d buffered message(s) to %s", len(r.buffer), path)
+	return nil
+}
+
+// LoadState восстанавливает буфер, сохранённый SaveState.
+func (r *Reassembler) LoadState(path string) error {
+	restored := make(map[string]*bufferedMessage)
+	if err := readJSON(path, &restored); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for msgID, buf := range restored {
+		// Отсчёт таймаута начинается заново после перезапуска
+		buf.ReceivedAt = time.Now()
+		r.buffer[msgID] = buf
+	}
+
+	if len(restored) > 0 {
+		log.Printf("[Reassembler] Restored %d buffered message(s) from %s", len(restored), path)
+	}
+	return nil
 }
 
 func calculateLastConfirmedIndex(segments map[int]string, total int) int {
@@ -140,36 +530,122 @@ func calculateLastConfirmedIndex(segments map[int]string, total int) int {
 	return total - 1
 }
 
-func joinSegments(parts map[int]string, total int) string {
+func joinSegments(parts map[int]string, from, to int) string {
 	var buf bytes.Buffer
-	for i := 0; i < total; i++ {
+	for i := from; i < to; i++ {
 		buf.WriteString(parts[i])
 	}
 	return buf.String()
 }
 
-func sendMessageToAppMars(msg model.Message, cfg *config.Config) {
-	data, _ := json.Marshal(msg)
-	url := cfg.AppMarsURL + "/receiveMessage"
+// open собирает содержимое сообщения и расшифровывает его, если отправитель
+// его зашифровал. Red- и green-часть зашифрованы отдельно; green-часть с
+// потерянными сегментами расшифровать нельзя, и она отбрасывается. Ошибка
+// расшифровки — доставка с HasError.
+func (r *Reassembler) open(messageID string, buf *bufferedMessage, greenLost bool) (string, bool) {
+	red := buf.redSegments()
+	parts := []string{joinSegments(buf.Segments, 0, red), joinSegments(buf.Segments, red, buf.TotalSegments)}
+	if !keyring.IsSealed(parts[0]) && !keyring.IsSealed(parts[1]) {
+		return parts[0] + parts[1], false
+	}
+
+	var content strings.Builder
+	names := []string{keyring.PartRed, keyring.PartGreen}
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		if i == 1 && greenLost {
+			log.Printf("[Reassembler] Encrypted green part of message %s is incomplete, dropping it", messageID)
+			continue
+		}
+		text, err := r.keyring.Open(part, keyring.AAD(buf.Sender, messageID, names[i]))
+		if err != nil {
+			log.Printf("[Reassembler] Failed to decrypt message %s: %v", messageID, err)
+			metrics.MessagesDecrypted.WithLabelValues(metrics.Sender(buf.Sender), metrics.OutcomeError).Inc()
+			return "", true
+		}
+		content.WriteString(text)
+	}
+	metrics.MessagesDecrypted.WithLabelValues(metrics.Sender(buf.Sender), metrics.OutcomeOK).Inc()
+	return content.String(), false
+}
+
+// deliverToApp передаёт собранное сообщение messageID приложению узла
+// назначения destination; пустой destination — приложению этого узла.
+// Содержимое сообщения в журнал не пишется.
+func deliverToApp(messageID string, msg model.Message, destination string, cfg *config.Config) {
+	app := cfg.AppURL(destination)
+	if app == "" {
+		log.Printf("[Reassembler] No application endpoint for node %q, message %s from %s dropped", destination, messageID, msg.Sender)
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("[Reassembler] Failed to encode message %s: %v", messageID, err)
+		return
+	}
+	url := app + "/receiveMessage"
 	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
-	if err != nil || resp.StatusCode != http.StatusOK {
-		log.Printf("[Reassembler] Failed to send message %s to app-mars: %v", msg.Sender, err)
+	if err != nil {
+		log.Printf("[Reassembler] Failed to send message %s to %s: %v", messageID, url, err)
 		return
 	}
-	log.Printf("[Reassembler] Message %s successfully sent to app-mars", msg.Content)
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[Reassembler] Failed to send message %s to %s: status code %d", messageID, url, resp.StatusCode)
+		return
+	}
+	log.Printf("[Reassembler] Message %s from %s delivered to %s", messageID, msg.Sender, url)
 }
 
-func sendAckToChannel(ack model.Ack, cfg *config.Config) {
-	data, _ := json.Marshal(ack)
+// SendAckToChannel подписывает ACK ключом этого узла и передаёт его в
+// канал. ACK, адресованный другому узлу, уходит следующему узлу маршрута
+// в ближайший сеанс связи с ним.
+func SendAckToChannel(ack model.Ack, cfg *config.Config, router *routing.Router, signer *auth.Authenticator) {
 	url := cfg.ChannelURL + "/processAck"
+	mode := ""
+	if !router.IsLocal(ack.Destination) {
+		data, _, err := framing.EncodeAck(cfg, "", ack)
+		if err != nil {
+			log.Printf("[Reassembler] Failed to encode ACK for message %s: %v", ack.MessageID, err)
+			return
+		}
+		hop, err := router.NextHop(ack.Destination, len(data))
+		if err != nil {
+			log.Printf("[Routing] Failed to route ACK for message %s: %v", ack.MessageID, err)
+			return
+		}
+		if err := hop.Wait(context.Background(), len(data)); err != nil {
+			log.Printf("[Routing] Failed to route ACK for message %s: %v", ack.MessageID, err)
+			return
+		}
+		url = hop.Node.URL + "/processAck"
+		mode = hop.Node.Framing
+	}
+
+	// Подпись ставится перед самой отправкой: ожидание сеанса связи не
+	// должно съедать окно защиты от повторов
+	ack, err := signer.SignAck(ack)
+	if err != nil {
+		log.Printf("[Reassembler] Failed to sign ACK for message %s: %v", ack.MessageID, err)
+		return
+	}
+	data, contentType, err := framing.EncodeAck(cfg, mode, ack)
+	if err != nil {
+		log.Printf("[Reassembler] Failed to encode ACK for message %s: %v", ack.MessageID, err)
+		return
+	}
 
 	const maxRetries = 3
 	const retryDelay = 3 * time.Second
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+		resp, err := http.Post(url, contentType, bytes.NewReader(data))
 		if err == nil && resp.StatusCode == http.StatusOK {
-			log.Printf("[Reassembler] ACK sent to channel: messageId=%s, lastConfirmed=%d, final=%v", ack.MessageID, ack.LastConfirmedSegment, ack.Final)
+			log.Printf("[Reassembler] ACK sent to channel: messageId=%s, lastConfirmed=%d, final=%v, custody=%v", ack.MessageID, ack.LastConfirmedSegment, ack.Final, ack.Custody)
 			return
 		}
 		log.Printf("[Reassembler] Failed to send ACK attempt #%d for message %s: %v", attempt, ack.MessageID, err)
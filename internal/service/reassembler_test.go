@@ -0,0 +1,144 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"transport/internal/auth"
+	"transport/internal/config"
+	"transport/internal/framing"
+	"transport/internal/model"
+	"transport/internal/routing"
+	"transport/internal/wire"
+)
+
+// newTestReassembler создаёт буфер сборки узла mars, ACK которого уходят
+// в тестовый канал.
+func newTestReassembler(t *testing.T, cfg config.Config) (*Reassembler, <-chan model.Ack) {
+	t.Helper()
+	acks := make(chan model.Ack, 16)
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		ack, err := framing.DecodeAck(r.Header.Get("Content-Type"), data)
+		if err != nil {
+			t.Errorf("channel got invalid ACK: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		acks <- ack
+	}))
+	t.Cleanup(channel.Close)
+
+	signer, err := auth.New("mars", map[string]string{"mars": "mars-secret-0123456789"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.NodeID = "mars"
+	cfg.ChannelURL = channel.URL
+	cfg.WireFormat = wire.FormatBinary
+	return NewReassembler(&cfg, NewEventBus(), routing.NewRouter("mars", ""), nil, signer), acks
+}
+
+func receiveAck(t *testing.T, acks <-chan model.Ack) model.Ack {
+	t.Helper()
+	select {
+	case ack := <-acks:
+		return ack
+	case <-time.After(5 * time.Second):
+		t.Fatal("no ACK sent to channel")
+		return model.Ack{}
+	}
+}
+
+func testSegment(messageID string, index, total int) model.Segment {
+	return model.Segment{
+		Sender:        "alice",
+		MessageID:     messageID,
+		SegmentIndex:  index,
+		TotalSegments: total,
+		Payload:       "p",
+		Source:        "earth",
+	}
+}
+
+// Сообщение, брошенное по таймауту сборки, завершается финальным ACK с
+// ошибкой: без Failed отправитель принял бы его за доставленное
+func TestReassemblyTimeoutFailedAck(t *testing.T) {
+	r, acks := newTestReassembler(t, config.Config{Timeout: time.Millisecond})
+	if !r.AddSegment(testSegment("m1", 0, 3)) {
+		t.Fatal("segment rejected")
+	}
+
+	// Первый таймаут фиксирует прогресс, следующие два — попытки без него
+	for i, final := range []bool{false, false, true} {
+		time.Sleep(5 * time.Millisecond)
+		r.CheckTimeoutsAndAssemble()
+		ack := receiveAck(t, acks)
+		if ack.MessageID != "m1" || ack.LastConfirmedSegment != 0 || ack.Final != final || ack.Failed != final {
+			t.Fatalf("ACK #%d = %+v, want lastConfirmed 0, final=failed=%v", i+1, ack, final)
+		}
+	}
+	if messages, _ := r.Stats(); messages != 0 {
+		t.Fatalf("buffer holds %d message(s) after failure, want 0", messages)
+	}
+}
+
+// Вытесненное из переполненного буфера сообщение тоже завершается
+// финальным ACK с ошибкой
+func TestEvictionFailedAck(t *testing.T) {
+	r, acks := newTestReassembler(t, config.Config{Timeout: time.Hour, ReassemblyMaxSegments: 1})
+	if !r.AddSegment(testSegment("old", 0, 2)) {
+		t.Fatal("segment rejected")
+	}
+	if !r.AddSegment(testSegment("new", 0, 2)) {
+		t.Fatal("segment of new message rejected instead of evicting old one")
+	}
+
+	ack := receiveAck(t, acks)
+	if ack.MessageID != "old" || ack.LastConfirmedSegment != 0 || !ack.Final || !ack.Failed {
+		t.Fatalf("eviction ACK = %+v, want final failed ACK for old with lastConfirmed 0", ack)
+	}
+	if messages, segments := r.Stats(); messages != 1 || segments != 1 {
+		t.Fatalf("buffer = %d message(s), %d segment(s), want 1, 1", messages, segments)
+	}
+}
+
+// Собранное сообщение доставляется приложению, а его содержимое не
+// попадает в журнал
+func TestDeliverToApp(t *testing.T) {
+	delivered := make(chan model.Message, 1)
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg model.Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("app got invalid message: %v", err)
+		}
+		delivered <- msg
+	}))
+	defer app.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	cfg := &config.Config{AppEndpoints: map[string]string{"mars": app.URL}, NodeID: "mars"}
+	deliverToApp("m1", model.Message{Sender: "alice", Content: "top secret"}, "", cfg)
+
+	select {
+	case msg := <-delivered:
+		if msg.Content != "top secret" || msg.Sender != "alice" {
+			t.Fatalf("app got %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not delivered")
+	}
+	if out := logs.String(); strings.Contains(out, "top secret") || !strings.Contains(out, "m1") {
+		t.Fatalf("log must name message m1 without its content: %q", out)
+	}
+}
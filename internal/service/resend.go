@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+	"transport/internal/metrics"
+	"transport/internal/model"
+	"transport/internal/queue"
+)
+
+// resendBatch — недостающие сегменты одного сообщения после ACK.
+type resendBatch struct {
+	segments      []model.Segment
+	lastConfirmed int
+}
+
+// resendQueue хранит повторные отправки с FIFO на каждый класс срочности:
+// повтор emergency-сообщения публикуется раньше накопившихся повторов bulk.
+type resendQueue struct {
+	mu      sync.Mutex
+	batches [][]resendBatch
+	notify  chan struct{}
+}
+
+func newResendQueue() *resendQueue {
+	return &resendQueue{
+		batches: make([][]resendBatch, len(model.Priorities)),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+func (q *resendQueue) push(b resendBatch) {
+	class := b.segments[0].Priority.Rank()
+
+	q.mu.Lock()
+	q.batches[class] = append(q.batches[class], b)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop возвращает пакет самого срочного класса или false, если очередь пуста.
+func (q *resendQueue) pop() (resendBatch, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for class, batches := range q.batches {
+		if len(batches) > 0 {
+			b := batches[0]
+			q.batches[class] = batches[1:]
+			return b, true
+		}
+	}
+	return resendBatch{}, false
+}
+
+// ScheduleResend ставит недостающие сегменты сообщения в очередь повторной
+// отправки; их публикует RunResends.
+func (a *AckTracker) ScheduleResend(segments []model.Segment, lastConfirmed int) {
+	if len(segments) == 0 {
+		return
+	}
+	a.resends.push(resendBatch{segments: segments, lastConfirmed: lastConfirmed})
+	log.Printf("[AckTracker] Resend of %d segment(s) of message %s scheduled (%s)",
+		len(segments), segments[0].MessageID, segments[0].Priority.Normalize())
+}
+
+// RunResends публикует запланированные повторы в порядке срочности, пока не
+// будет отменён ctx. Повторы, не опубликованные к остановке, будут заново
+// запрошены следующим ACK или таймаутом.
+func (a *AckTracker) RunResends(ctx context.Context, pub queue.Publisher) {
+	for {
+		b, ok := a.resends.pop()
+		if !ok {
+			select {
+			case <-a.resends.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		messageID := b.segments[0].MessageID
+		if b.segments[0].Expired(time.Now()) {
+			// Срок истёк, пока повтор ждал очереди: сообщение завершается как expired
+			log.Printf("[AckTracker] Resend of message %s skipped: message expired", messageID)
+			metrics.SegmentsExpired.WithLabelValues(metrics.Sender(b.segments[0].Sender), metrics.StageAckTracker).Add(float64(len(b.segments)))
+			a.expireIfDue(messageID, time.Now())
+			continue
+		}
+
+		outcome := metrics.OutcomeOK
+		log.Printf("[AckTracker] Resending %d segment(s) of message %s", len(b.segments), messageID)
+		if err := pub.SendSegments(ctx, b.segments); err != nil {
+			log.Printf("[AckTracker] Failed to resend segments of %s: %v", messageID, err)
+			outcome = metrics.OutcomeError
+		}
+
+		for _, seg := range b.segments {
+			metrics.SegmentsResent.WithLabelValues(metrics.Sender(seg.Sender), outcome).Inc()
+
+			a.events.Publish(model.Event{
+				Type:          model.StreamResendTriggered,
+				MessageID:     seg.MessageID,
+				Sender:        seg.Sender,
+				SegmentIndex:  seg.SegmentIndex,
+				TotalSegments: seg.TotalSegments,
+				LastConfirmed: b.lastConfirmed,
+			})
+		}
+	}
+}
@@ -5,28 +5,33 @@ import (
 	"transport/internal/model"
 )
 
+// SplitMessageToSegments режет сообщение на сегменты. При заданном RedLength
+// red-префикс и green-остаток режутся отдельно, чтобы ни один сегмент не
+// смешивал надёжные и ненадёжные данные; green-сегменты идут последними.
 func SplitMessageToSegments(req model.SendMessageRequest, maxSegmentSize int) []model.Segment {
 	msg := []byte(req.Message)
-	total := (len(msg) + maxSegmentSize - 1) / maxSegmentSize
-	segments := make([]model.Segment, 0, total)
-
-	log.Printf("[Segmenter] Splitting message %s from %s into %d segments (size=%d)", req.MessageID, req.Sender, total, maxSegmentSize)
+	red, green := msg, []byte(nil)
+	if req.RedLength != nil {
+		red, green = msg[:*req.RedLength], msg[*req.RedLength:]
+	}
 
-	for i := 0; i < total; i++ {
-		start := i * maxSegmentSize
-		end := start + maxSegmentSize
-		if end > len(msg) {
-			end = len(msg)
-		}
+	redParts := splitBytes(red, maxSegmentSize)
+	greenParts := splitBytes(green, maxSegmentSize)
+	total := len(redParts) + len(greenParts)
+	segments := make([]model.Segment, 0, total)
 
-		payload := msg[start:end]
+	log.Printf("[Segmenter] Splitting message %s from %s into %d segments (size=%d, green=%d, priority=%s)",
+		req.MessageID, req.Sender, total, maxSegmentSize, len(greenParts), req.Priority)
 
+	for i, payload := range append(redParts, greenParts...) {
 		segment := model.Segment{
 			Sender:        req.Sender,
 			MessageID:     req.MessageID,
 			SegmentIndex:  i,
 			TotalSegments: total,
 			Payload:       string(payload),
+			GreenSegments: len(greenParts),
+			Priority:      req.Priority,
 		}
 
 		log.Printf("[Segmenter] Segment %d: %d bytes", i, len(payload))
@@ -35,3 +40,15 @@ func SplitMessageToSegments(req model.SendMessageRequest, maxSegmentSize int) []
 
 	return segments
 }
+
+func splitBytes(data []byte, size int) [][]byte {
+	parts := make([][]byte, 0, (len(data)+size-1)/size)
+	for start := 0; start < len(data); start += size {
+		end := start + size
+		if end > len(data) {
+			end = len(data)
+		}
+		parts = append(parts, data[start:end])
+	}
+	return parts
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+	"transport/internal/config"
+	"transport/internal/model"
+)
+
+const (
+	SignatureHeader = "X-Marslink-Signature"
+	TimestampHeader = "X-Marslink-Timestamp"
+	EventHeader     = "X-Marslink-Event"
+)
+
+type messageSubscription struct {
+	Sender      string
+	CallbackURL string
+}
+
+// WebhookRegistry хранит webhook-и отправителей и рассылает события
+// жизненного цикла сообщений через собственный Outbox с HMAC-подписью.
+type WebhookRegistry struct {
+	mu       sync.Mutex
+	path     string
+	hooks    map[string][]model.Webhook
+	messages map[string]messageSubscription
+	secret   string
+	outbox   *Outbox
+	// allowed проверяет адрес по WEBHOOK_ALLOWED_HOSTS перед отправкой:
+	// сохранённые раньше webhook-и могли выпасть из списка
+	allowed func(url string) bool
+}
+
+func NewWebhookRegistry(cfg *config.Config, outbox *Outbox) *WebhookRegistry {
+	r := &WebhookRegistry{
+		path:     cfg.WebhooksPath,
+		hooks:    make(map[string][]model.Webhook),
+		messages: make(map[string]messageSubscription),
+		secret:   cfg.WebhookSecret,
+		outbox:   outbox,
+		allowed:  cfg.WebhookAllowed,
+	}
+
+	if err := r.load(); err != nil {
+		log.Printf("[Webhooks] Failed to load %s: %v", r.path, err)
+	}
+
+	return r
+}
+
+func (r *WebhookRegistry) Register(hook model.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hooks := r.hooks[hook.Sender]
+	for i, h := range hooks {
+		if h.URL == hook.URL {
+			hooks[i] = hook
+			return r.persist()
+		}
+	}
+	r.hooks[hook.Sender] = append(hooks, hook)
+	log.Printf("[Webhooks] Registered %s for sender %s", hook.URL, hook.Sender)
+
+	return r.persist()
+}
+
+// Unregister удаляет webhook отправителя. Пустой url удаляет все webhook-и отправителя.
+func (r *WebhookRegistry) Unregister(sender, url string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hooks, ok := r.hooks[sender]
+	if !ok {
+		return false, nil
+	}
+
+	if url == "" {
+		delete(r.hooks, sender)
+		return true, r.persist()
+	}
+
+	for i, h := range hooks {
+		if h.URL == url {
+			hooks = append(hooks[:i], hooks[i+1:]...)
+			if len(hooks) == 0 {
+				delete(r.hooks, sender)
+			} else {
+				r.hooks[sender] = hooks
+			}
+			return true, r.persist()
+		}
+	}
+
+	return false, nil
+}
+
+func (r *WebhookRegistry) List() []model.Webhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]model.Webhook, 0)
+	for _, hooks := range r.hooks {
+		for _, h := range hooks {
+			h.Secret = ""
+			list = append(list, h)
+		}
+	}
+	return list
+}
+
+// Watch связывает сообщение с отправителем и необязательным callback URL,
+// чтобы последующие события можно было адресовать по messageId.
+func (r *WebhookRegistry) Watch(messageID, sender, callbackURL string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages[messageID] = messageSubscription{
+		Sender:      sender,
+		CallbackURL: callbackURL,
+	}
+}
+
+func (r *WebhookRegistry) Notify(messageID, event string, totalSegments, lastConfirmed int) {
+	r.mu.Lock()
+	sub, ok := r.messages[messageID]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+
+	targets := make([]model.Webhook, 0, len(r.hooks[sub.Sender])+1)
+	targets = append(targets, r.hooks[sub.Sender]...)
+	if sub.CallbackURL != "" {
+		targets = append(targets, model.Webhook{Sender: sub.Sender, URL: sub.CallbackURL})
+	}
+
+	if isTerminalEvent(event) {
+		delete(r.messages, messageID)
+	}
+	r.mu.Unlock()
+
+	if len(targets) == 0 {
+		return
+	}
+
+	now := time.Now()
+	data, err := json.Marshal(model.DeliveryEvent{
+		Event:                event,
+		MessageID:            messageID,
+		Sender:               sub.Sender,
+		TotalSegments:        totalSegments,
+		LastConfirmedSegment: lastConfirmed,
+		Timestamp:            now,
+	})
+	if err != nil {
+		log.Printf("[Webhooks] Failed to marshal %s event for %s: %v", event, messageID, err)
+		return
+	}
+
+	for _, hook := range targets {
+		if !r.allowed(hook.URL) {
+			log.Printf("[Webhooks] Skipping %s event for %s: %s is not in WEBHOOK_ALLOWED_HOSTS", event, messageID, hook.URL)
+			continue
+		}
+		secret := hook.Secret
+		if secret == "" {
+			secret = r.secret
+		}
+
+		headers := map[string]string{
+			EventHeader:     event,
+			TimestampHeader: strconv.FormatInt(now.Unix(), 10),
+		}
+		if secret != "" {
+			headers[SignatureHeader] = Sign(secret, now, data)
+		}
+
+		if err := r.outbox.EnqueueRaw(hook.URL, data, headers); err != nil {
+			log.Printf("[Webhooks] Failed to queue %s event for %s to %s: %v", event, messageID, hook.URL, err)
+		}
+	}
+}
+
+// Sign возвращает значение заголовка подписи:
+// "sha256=" + hex(HMAC-SHA256(timestamp + "." + body)), где timestamp —
+// значение заголовка X-Marslink-Timestamp (Unix-время события в секундах).
+// Метка времени входит в подпись, поэтому получатель может отвергать
+// повторно присланные старые события; повторы outbox сохраняют её, и окно
+// проверки должно быть не короче WEBHOOK_TTL.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func isTerminalEvent(event string) bool {
+	switch event {
+	case model.EventDelivered, model.EventFailed, model.EventCancelled, model.EventExpired, model.EventSent:
+		return true
+	}
+	return false
+}
+
+func (r *WebhookRegistry) load() error {
+	if r.path == "" {
+		return nil
+	}
+	return readJSON(r.path, &r.hooks)
+}
+
+// persist вызывается под r.mu.
+func (r *WebhookRegistry) persist() error {
+	if r.path == "" {
+		return nil
+	}
+	return writeJSONAtomic(r.path, r.hooks, 0o600)
+}
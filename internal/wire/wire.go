@@ -0,0 +1,412 @@
+// Package wire описывает формат сегментов и ACK на проводе: в очереди и
+// между транспортом и каналом. Помимо JSON поддерживается компактный
+// бинарный конверт с версией схемы:
+//
+//	0   1   2       3    4      5 ...
+//	'M' 'L' version kind flags  тело в кодировке protobuf
+//
+// Поля тела нумеруются как в protobuf-схеме, поэтому новые поля можно
+// добавлять без смены версии: старые декодеры их пропускают.
+package wire
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"transport/internal/model"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Format — кодировка сегментов и ACK, которую транспорт использует при отправке.
+type Format string
+
+const (
+	FormatJSON   Format = "json"
+	FormatBinary Format = "binary"
+)
+
+const (
+	ContentTypeJSON   = "application/json"
+	ContentTypeBinary = "application/vnd.marslink+protobuf"
+)
+
+// Version — текущая версия схемы бинарного конверта.
+const Version = 1
+
+// Kind — тип полезной нагрузки конверта.
+type Kind uint8
+
+const (
+	KindSegment Kind = 1
+	KindAck     Kind = 2
+)
+
+// knownFlags — флаги, которые понимает эта версия декодера. Конверт с
+// неизвестным флагом отклоняется: он может означать преобразование тела,
+// которое нельзя проигнорировать.
+const knownFlags = 0
+
+const headerSize = 5
+
+var magic = [2]byte{'M', 'L'}
+
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrUnsupportedVersion   = errors.New("unsupported envelope version")
+)
+
+// Header — заголовок бинарного конверта.
+type Header struct {
+	Version uint8
+	Kind    Kind
+	Flags   uint8
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatJSON, FormatBinary:
+		return Format(s), nil
+	}
+	return "", fmt.Errorf("unknown wire format %q (expected json or binary)", s)
+}
+
+func (f Format) ContentType() string {
+	if f == FormatBinary {
+		return ContentTypeBinary
+	}
+	return ContentTypeJSON
+}
+
+// FormatFromContentType определяет кодировку тела запроса. Пустой
+// Content-Type трактуется как JSON — так отправляют старые клиенты.
+func FormatFromContentType(contentType string) (Format, error) {
+	if contentType == "" {
+		return FormatJSON, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnsupportedMediaType, err)
+	}
+	switch mediaType {
+	case ContentTypeJSON:
+		return FormatJSON, nil
+	case ContentTypeBinary:
+		return FormatBinary, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+}
+
+// IsBinary сообщает, начинаются ли данные с сигнатуры бинарного конверта.
+func IsBinary(data []byte) bool {
+	return len(data) >= len(magic) && data[0] == magic[0] && data[1] == magic[1]
+}
+
+// CheckFormat проверяет, что тело закодировано в формате, заявленном в Content-Type.
+func CheckFormat(f Format, data []byte) error {
+	if (f == FormatBinary) != IsBinary(data) {
+		return fmt.Errorf("body is not %s", f.ContentType())
+	}
+	return nil
+}
+
+// ReadHeader разбирает заголовок конверта и возвращает тело.
+func ReadHeader(data []byte) (Header, []byte, error) {
+	if !IsBinary(data) || len(data) < headerSize {
+		return Header{}, nil, errors.New("not a binary envelope")
+	}
+	h := Header{Version: data[2], Kind: Kind(data[3]), Flags: data[4]}
+	if h.Version != Version {
+		return h, nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, h.Version)
+	}
+	if h.Flags&^knownFlags != 0 {
+		return h, nil, fmt.Errorf("unsupported envelope flags %#x", h.Flags)
+	}
+	return h, data[headerSize:], nil
+}
+
+func appendHeader(b []byte, kind Kind, flags uint8) []byte {
+	return append(b, magic[0], magic[1], Version, byte(kind), flags)
+}
+
+// Номера полей тела сегмента
+const (
+	segmentSender        protowire.Number = 1
+	segmentMessageID     protowire.Number = 2
+	segmentIndex         protowire.Number = 3
+	segmentTotalSegments protowire.Number = 4
+	segmentPayload       protowire.Number = 5
+	segmentGreenSegments protowire.Number = 6
+	segmentPriority      protowire.Number = 7
+	segmentExpiresAt     protowire.Number = 8
+	segmentSource        protowire.Number = 9
+	segmentDestination   protowire.Number = 10
+	segmentCustodian     protowire.Number = 11
+	segmentSigner        protowire.Number = 12
+	segmentSignedAt      protowire.Number = 13
+	segmentSignature     protowire.Number = 14
+)
+
+// Номера полей тела ACK
+const (
+	ackMessageID     protowire.Number = 1
+	ackLastConfirmed protowire.Number = 2
+	ackFinal         protowire.Number = 3
+	ackDestination   protowire.Number = 4
+	ackCustody       protowire.Number = 5
+	ackSegments      protowire.Number = 6
+	ackSigner        protowire.Number = 7
+	ackSignedAt      protowire.Number = 8
+	ackSignature     protowire.Number = 9
+	ackFailed        protowire.Number = 10
+)
+
+// EncodeSegment кодирует сегмент в формате f.
+func EncodeSegment(f Format, seg model.Segment) ([]byte, error) {
+	if f != FormatBinary {
+		return json.Marshal(seg)
+	}
+
+	b := make([]byte, 0, headerSize+len(seg.Sender)+len(seg.MessageID)+len(seg.Payload)+16)
+	b = appendHeader(b, KindSegment, 0)
+	b = appendString(b, segmentSender, seg.Sender)
+	b = appendString(b, segmentMessageID, seg.MessageID)
+	b = appendVarint(b, segmentIndex, uint64(seg.SegmentIndex))
+	b = appendVarint(b, segmentTotalSegments, uint64(seg.TotalSegments))
+	b = appendString(b, segmentPayload, seg.Payload)
+	b = appendVarint(b, segmentGreenSegments, uint64(seg.GreenSegments))
+	b = appendString(b, segmentPriority, string(seg.Priority))
+	b = appendVarint(b, segmentExpiresAt, uint64(seg.ExpiresAt))
+	b = appendString(b, segmentSource, seg.Source)
+	b = appendString(b, segmentDestination, seg.Destination)
+	b = appendString(b, segmentCustodian, seg.Custodian)
+	b = appendString(b, segmentSigner, seg.Signer)
+	b = appendVarint(b, segmentSignedAt, uint64(seg.SignedAt))
+	b = appendString(b, segmentSignature, seg.Signature)
+	return b, nil
+}
+
+// DecodeSegment декодирует сегмент, определяя формат по содержимому.
+func DecodeSegment(data []byte) (model.Segment, error) {
+	var seg model.Segment
+	if !IsBinary(data) {
+		err := json.Unmarshal(data, &seg)
+		return seg, err
+	}
+
+	h, body, err := ReadHeader(data)
+	if err != nil {
+		return seg, err
+	}
+	if h.Kind != KindSegment {
+		return seg, fmt.Errorf("envelope kind %d is not a segment", h.Kind)
+	}
+
+	err = consumeFields(body, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == segmentSender && typ == protowire.BytesType:
+			return consumeString(b, &seg.Sender)
+		case num == segmentMessageID && typ == protowire.BytesType:
+			return consumeString(b, &seg.MessageID)
+		case num == segmentIndex && typ == protowire.VarintType:
+			return consumeInt(b, &seg.SegmentIndex)
+		case num == segmentTotalSegments && typ == protowire.VarintType:
+			return consumeInt(b, &seg.TotalSegments)
+		case num == segmentPayload && typ == protowire.BytesType:
+			return consumeString(b, &seg.Payload)
+		case num == segmentGreenSegments && typ == protowire.VarintType:
+			return consumeInt(b, &seg.GreenSegments)
+		case num == segmentPriority && typ == protowire.BytesType:
+			var p string
+			n, err := consumeString(b, &p)
+			seg.Priority = model.Priority(p)
+			return n, err
+		case num == segmentExpiresAt && typ == protowire.VarintType:
+			return consumeInt64(b, &seg.ExpiresAt)
+		case num == segmentSource && typ == protowire.BytesType:
+			return consumeString(b, &seg.Source)
+		case num == segmentDestination && typ == protowire.BytesType:
+			return consumeString(b, &seg.Destination)
+		case num == segmentCustodian && typ == protowire.BytesType:
+			return consumeString(b, &seg.Custodian)
+		case num == segmentSigner && typ == protowire.BytesType:
+			return consumeString(b, &seg.Signer)
+		case num == segmentSignedAt && typ == protowire.VarintType:
+			return consumeInt64(b, &seg.SignedAt)
+		case num == segmentSignature && typ == protowire.BytesType:
+			return consumeString(b, &seg.Signature)
+		}
+		return -1, nil
+	})
+	return seg, err
+}
+
+// EncodeAck кодирует ACK в формате f.
+func EncodeAck(f Format, ack model.Ack) ([]byte, error) {
+	if f != FormatBinary {
+		return json.Marshal(ack)
+	}
+
+	b := make([]byte, 0, headerSize+len(ack.MessageID)+16)
+	b = appendHeader(b, KindAck, 0)
+	b = appendString(b, ackMessageID, ack.MessageID)
+	// lastConfirmed бывает -1, поэтому zigzag, как sint64 в protobuf
+	b = appendVarint(b, ackLastConfirmed, protowire.EncodeZigZag(int64(ack.LastConfirmedSegment)))
+	if ack.Final {
+		b = appendVarint(b, ackFinal, 1)
+	}
+	b = appendString(b, ackDestination, ack.Destination)
+	if ack.Custody {
+		b = appendVarint(b, ackCustody, 1)
+	}
+	// Индекс 0 — допустимое значение, поэтому поле пишется всегда
+	for _, i := range ack.Segments {
+		b = protowire.AppendTag(b, ackSegments, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(i))
+	}
+	b = appendString(b, ackSigner, ack.Signer)
+	b = appendVarint(b, ackSignedAt, uint64(ack.SignedAt))
+	b = appendString(b, ackSignature, ack.Signature)
+	if ack.Failed {
+		b = appendVarint(b, ackFailed, 1)
+	}
+	return b, nil
+}
+
+// DecodeAck декодирует ACK, определяя формат по содержимому.
+func DecodeAck(data []byte) (model.Ack, error) {
+	var ack model.Ack
+	if !IsBinary(data) {
+		err := json.Unmarshal(data, &ack)
+		return ack, err
+	}
+
+	h, body, err := ReadHeader(data)
+	if err != nil {
+		return ack, err
+	}
+	if h.Kind != KindAck {
+		return ack, fmt.Errorf("envelope kind %d is not an ack", h.Kind)
+	}
+
+	err = consumeFields(body, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == ackMessageID && typ == protowire.BytesType:
+			return consumeString(b, &ack.MessageID)
+		case num == ackLastConfirmed && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return n, protowire.ParseError(n)
+			}
+			ack.LastConfirmedSegment = int(protowire.DecodeZigZag(v))
+			return n, nil
+		case num == ackFinal && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return n, protowire.ParseError(n)
+			}
+			ack.Final = protowire.DecodeBool(v)
+			return n, nil
+		case num == ackDestination && typ == protowire.BytesType:
+			return consumeString(b, &ack.Destination)
+		case num == ackCustody && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return n, protowire.ParseError(n)
+			}
+			ack.Custody = protowire.DecodeBool(v)
+			return n, nil
+		case num == ackSegments && typ == protowire.VarintType:
+			var i int
+			n, err := consumeInt(b, &i)
+			if err == nil {
+				ack.Segments = append(ack.Segments, i)
+			}
+			return n, err
+		case num == ackSigner && typ == protowire.BytesType:
+			return consumeString(b, &ack.Signer)
+		case num == ackSignedAt && typ == protowire.VarintType:
+			return consumeInt64(b, &ack.SignedAt)
+		case num == ackSignature && typ == protowire.BytesType:
+			return consumeString(b, &ack.Signature)
+		case num == ackFailed && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return n, protowire.ParseError(n)
+			}
+			ack.Failed = protowire.DecodeBool(v)
+			return n, nil
+		}
+		return -1, nil
+	})
+	return ack, err
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// consumeFields обходит поля тела. field возвращает число прочитанных байт
+// значения или -1, если поле неизвестно, — тогда оно пропускается.
+func consumeFields(b []byte, field func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		n, err := field(num, typ, b)
+		if err != nil {
+			return fmt.Errorf("field %d: %w", num, err)
+		}
+		if n < 0 {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+			}
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func consumeString(b []byte, dst *string) (int, error) {
+	v, n := protowire.ConsumeString(b)
+	if n < 0 {
+		return n, protowire.ParseError(n)
+	}
+	*dst = v
+	return n, nil
+}
+
+func consumeInt(b []byte, dst *int) (int, error) {
+	v, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return n, protowire.ParseError(n)
+	}
+	*dst = int(v)
+	return n, nil
+}
+
+func consumeInt64(b []byte, dst *int64) (int, error) {
+	v, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return n, protowire.ParseError(n)
+	}
+	*dst = int64(v)
+	return n, nil
+}
@@ -0,0 +1,263 @@
+package wire
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"transport/internal/model"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Сегмент размера SEGMENT_SIZE по умолчанию (120 байт полезной нагрузки)
+var benchSegment = model.Segment{
+	Sender:        "app-earth",
+	MessageID:     "3f2b9c1e-8a4d-4c6e-9b7a-1d2e3f4a5b6c",
+	SegmentIndex:  17,
+	TotalSegments: 42,
+	Payload:       strings.Repeat("telemetry ", 12),
+}
+
+var benchAck = model.Ack{
+	MessageID:            "3f2b9c1e-8a4d-4c6e-9b7a-1d2e3f4a5b6c",
+	LastConfirmedSegment: 17,
+}
+
+func fullSegment() model.Segment {
+	return model.Segment{
+		Sender:        "app-earth",
+		MessageID:     "m1",
+		SegmentIndex:  0,
+		TotalSegments: 3,
+		Payload:       "telemetry",
+		GreenSegments: 1,
+		Priority:      model.Priority("expedited"),
+		ExpiresAt:     1792411200000,
+		Source:        "earth",
+		Destination:   "mars",
+		Custodian:     "mro",
+		Auth:          model.Auth{Signer: "earth", SignedAt: 1792411200000000000, Signature: "c0ffee"},
+	}
+}
+
+func TestSegmentRoundTrip(t *testing.T) {
+	for _, f := range []Format{FormatJSON, FormatBinary} {
+		for _, seg := range []model.Segment{fullSegment(), benchSegment, {}} {
+			data, err := EncodeSegment(f, seg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if IsBinary(data) != (f == FormatBinary) {
+				t.Fatalf("%s: IsBinary = %v", f, IsBinary(data))
+			}
+			got, err := DecodeSegment(data)
+			if err != nil {
+				t.Fatalf("%s: %v", f, err)
+			}
+			if got != seg {
+				t.Errorf("%s: round trip = %+v, want %+v", f, got, seg)
+			}
+		}
+	}
+}
+
+func TestAckRoundTrip(t *testing.T) {
+	acks := []model.Ack{
+		benchAck,
+		// -1 — ещё ни один сегмент не подтверждён
+		{MessageID: "m1", LastConfirmedSegment: -1},
+		// Индекс 0 в Segments — обычный сегмент, а не пустое значение
+		{MessageID: "m1", LastConfirmedSegment: 0, Custody: true, Segments: []int{0}},
+		{MessageID: "m1", LastConfirmedSegment: 2, Custody: true, Segments: []int{0, 2, 1}},
+		{
+			MessageID:            "m1",
+			LastConfirmedSegment: 4,
+			Final:                true,
+			Failed:               true,
+			Destination:          "earth",
+			Auth:                 model.Auth{Signer: "mars", SignedAt: 42, Signature: "beef"},
+		},
+	}
+	for _, f := range []Format{FormatJSON, FormatBinary} {
+		for _, ack := range acks {
+			data, err := EncodeAck(f, ack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := DecodeAck(data)
+			if err != nil {
+				t.Fatalf("%s: %v", f, err)
+			}
+			if !reflect.DeepEqual(got, ack) {
+				t.Errorf("%s: round trip = %+v, want %+v", f, got, ack)
+			}
+		}
+	}
+}
+
+func TestDecodeRejectsHeader(t *testing.T) {
+	seg, err := EncodeSegment(FormatBinary, fullSegment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack, err := EncodeAck(FormatBinary, benchAck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	with := func(data []byte, i int, v byte) []byte {
+		b := append([]byte(nil), data...)
+		b[i] = v
+		return b
+	}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		decode func([]byte) error
+		want   string
+	}{
+		{"short header", seg[:3], decodeSegmentErr, "not a binary envelope"},
+		{"bad version", with(seg, 2, Version+1), decodeSegmentErr, "unsupported envelope version"},
+		{"unknown flags", with(seg, 4, 0x01), decodeSegmentErr, "unsupported envelope flags"},
+		{"unknown kind", with(seg, 3, 9), decodeSegmentErr, "is not a segment"},
+		{"ack as segment", ack, decodeSegmentErr, "is not a segment"},
+		{"segment as ack", seg, decodeAckErr, "is not an ack"},
+		{"unknown ack flags", with(ack, 4, 0x80), decodeAckErr, "unsupported envelope flags"},
+		{"truncated body", seg[:len(seg)-2], decodeSegmentErr, "field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.decode(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("decode error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := DecodeSegment(with(seg, 2, 2)); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("DecodeSegment error = %v, want ErrUnsupportedVersion", err)
+	}
+}
+
+func decodeSegmentErr(data []byte) error { _, err := DecodeSegment(data); return err }
+func decodeAckErr(data []byte) error     { _, err := DecodeAck(data); return err }
+
+// Без сигнатуры 'M' 'L' тело разбирается как JSON: так пишут старые узлы
+func TestJSONFallback(t *testing.T) {
+	seg, err := DecodeSegment([]byte(`{"sender":"app","messageId":"m1","segmentIndex":1,"totalSegments":2,"payload":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (model.Segment{Sender: "app", MessageID: "m1", SegmentIndex: 1, TotalSegments: 2, Payload: "hi"}); seg != want {
+		t.Errorf("DecodeSegment = %+v, want %+v", seg, want)
+	}
+
+	ack, err := DecodeAck([]byte(`{"messageId":"m1","lastConfirmedSegment":-1,"final":false}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.MessageID != "m1" || ack.LastConfirmedSegment != -1 {
+		t.Errorf("DecodeAck = %+v", ack)
+	}
+
+	// Испорченное тело без сигнатуры — ошибка JSON, а не бинарного конверта
+	if _, err := DecodeSegment([]byte("MX\x01\x01\x00")); err == nil {
+		t.Error("DecodeSegment accepted garbage")
+	}
+}
+
+// Поле с неизвестным номером пропускается: его мог добавить более новый узел
+func TestUnknownFieldSkipped(t *testing.T) {
+	data, err := EncodeAck(FormatBinary, benchAck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = protowire.AppendTag(data, 99, protowire.BytesType)
+	data = protowire.AppendString(data, "from the future")
+
+	ack, err := DecodeAck(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ack, benchAck) {
+		t.Errorf("DecodeAck = %+v, want %+v", ack, benchAck)
+	}
+}
+
+func TestFormatFromContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        Format
+		err         bool
+	}{
+		{"", FormatJSON, false},
+		{"application/json; charset=utf-8", FormatJSON, false},
+		{ContentTypeBinary, FormatBinary, false},
+		{"text/plain", "", true},
+	}
+	for _, tt := range tests {
+		got, err := FormatFromContentType(tt.contentType)
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("FormatFromContentType(%q) = %q, %v", tt.contentType, got, err)
+		}
+		if tt.err && !errors.Is(err, ErrUnsupportedMediaType) {
+			t.Errorf("FormatFromContentType(%q) error = %v, want ErrUnsupportedMediaType", tt.contentType, err)
+		}
+	}
+}
+
+// Метрика bytes/segment показывает размер закодированного сегмента;
+// разница между JSON и binary — экономия канала на каждом сегменте.
+func benchmarkEncodeSegment(b *testing.B, f Format) {
+	var size int
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := EncodeSegment(f, benchSegment)
+		if err != nil {
+			b.Fatal(err)
+		}
+		size = len(data)
+	}
+	b.ReportMetric(float64(size), "bytes/segment")
+}
+
+func BenchmarkEncodeSegmentJSON(b *testing.B)   { benchmarkEncodeSegment(b, FormatJSON) }
+func BenchmarkEncodeSegmentBinary(b *testing.B) { benchmarkEncodeSegment(b, FormatBinary) }
+
+func benchmarkDecodeSegment(b *testing.B, f Format) {
+	data, err := EncodeSegment(f, benchSegment)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		seg, err := DecodeSegment(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if seg != benchSegment {
+			b.Fatalf("round trip mismatch: %+v", seg)
+		}
+	}
+}
+
+func BenchmarkDecodeSegmentJSON(b *testing.B)   { benchmarkDecodeSegment(b, FormatJSON) }
+func BenchmarkDecodeSegmentBinary(b *testing.B) { benchmarkDecodeSegment(b, FormatBinary) }
+
+func benchmarkEncodeAck(b *testing.B, f Format) {
+	var size int
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := EncodeAck(f, benchAck)
+		if err != nil {
+			b.Fatal(err)
+		}
+		size = len(data)
+	}
+	b.ReportMetric(float64(size), "bytes/ack")
+}
+
+func BenchmarkEncodeAckJSON(b *testing.B)   { benchmarkEncodeAck(b, FormatJSON) }
+func BenchmarkEncodeAckBinary(b *testing.B) { benchmarkEncodeAck(b, FormatBinary) }
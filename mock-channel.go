@@ -24,24 +24,28 @@ func handleProcessSegment(w http.ResponseWriter, r *http.Request) {
 		log.Println("Segment lost")
 		return
 	}
-	forward(r.Body, getEnv("FORWARD_SEGMENT_TO", "http://localhost:4000/transferSegment"))
+	forward(r, getEnv("FORWARD_SEGMENT_TO", "http://localhost:4000/transferSegment"))
 	w.WriteHeader(http.StatusOK)
 }
 
 func handleProcessAck(w http.ResponseWriter, r *http.Request) {
-	forward(r.Body, getEnv("FORWARD_ACK_TO", "http://localhost:4000/transferAck"))
+	forward(r, getEnv("FORWARD_ACK_TO", "http://localhost:4000/transferAck"))
 	w.WriteHeader(http.StatusOK)
 }
 
-func forward(body io.ReadCloser, targetURL string) {
-	defer body.Close()
-	data, err := io.ReadAll(body)
+func forward(r *http.Request, targetURL string) {
+	defer r.Body.Close()
+	data, err := io.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("read error: %v", err)
 		return
 	}
 
-	resp, err := http.Post(targetURL, "application/json", bytes.NewReader(data))
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	resp, err := http.Post(targetURL, contentType, bytes.NewReader(data))
 	if err != nil {
 		log.Printf("forward error to %s: %v", targetURL, err)
 		return